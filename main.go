@@ -2,51 +2,324 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/joho/godotenv"
 	"github.com/spf13/cobra"
+	"github.com/zmb3/spotify/v2"
+	"io"
 	"log"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"playlist-download/src/auth"
+	"playlist-download/src/config"
+	"playlist-download/src/covers"
 	"playlist-download/src/downloader"
+	"playlist-download/src/events"
+	"playlist-download/src/importer"
+	"playlist-download/src/lyrics"
+	"playlist-download/src/naming"
+	"playlist-download/src/overrides"
 	"playlist-download/src/parser"
+	"playlist-download/src/playlistfile"
+	"playlist-download/src/report"
 	"playlist-download/src/utils"
+	yt "playlist-download/src/yt"
 	"strings"
+	"syscall"
+	"text/tabwriter"
 )
 
+// usageTemplate is the usage of every command, like cobra's default one with the
+// sub-commands and examples first.
+const usageTemplate = `Usage:{{if .Runnable}}
+  {{.UseLine}}{{end}}{{if .HasAvailableSubCommands}}
+  {{.CommandPath}} [command]
+
+Commands:{{range .Commands}}{{if .IsAvailableCommand}}
+  {{rpad .Name .NamePadding}} {{.Short}}{{end}}{{end}}{{end}}{{if .HasExample}}
+
+Examples:
+{{.Example}}{{end}}{{if .HasAvailableLocalFlags}}
+
+Flags:
+{{.LocalFlags.FlagUsages | trimTrailingWhitespaces}}{{end}}{{if .HasAvailableInheritedFlags}}
+
+Global Flags:
+{{.InheritedFlags.FlagUsages | trimTrailingWhitespaces}}{{end}}
+`
+
 func main() {
-	err := godotenv.Load()
-	if err != nil {
-		log.Fatal("Error loading .env file")
+	// A .env in the current directory is optional: the settings can also come from the
+	// environment or the config file. Variables already set in the environment win.
+	if err := godotenv.Load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("Error loading .env file: %v", err)
 	}
 
-	ctx := context.Background()
+	// Ctrl-C or SIGTERM cancel the context: the running yt-dlp/ffmpeg processes are killed,
+	// partial files deleted and the state saved. A second Ctrl-C quits immediately.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		// From now on the signals get their default behavior back
+		signal.Stop(signals)
+		fmt.Fprintln(os.Stderr, "\n=> Interrupted, stopping the downloads (press Ctrl-C again to quit immediately)...")
+		cancel()
+	}()
+
 	var outputDir string
 	var workerCount int
 	var cookies string
+	var removal string
+	var searchBackend string
+	var isrcSearch string
+	var overridesPath string
+	var albumTypes string
+	var topTracks bool
+	var market string
+	var dryRun bool
+	var reportPath string
+	var audioFormat string
+	var nameTemplate string
+	var collision string
+	var playlistFormats string
+	var coverMode string
+	var withLyrics bool
+	var lyricsURL string
+	var lrcSidecar bool
+	var outputFormat string
+	var configPath string
+	var inputFile string
+	var csvColumns string
+	var enrich bool
+	var profile string
+
+	// humanOut is where the messages meant for people go: stderr when stdout carries the JSON events
+	var humanOut io.Writer = os.Stdout
+
+	// loadConfig reads the config file given with --config, or the default one
+	loadConfig := func() (*config.Config, error) {
+		path := configPath
+		if path == "" {
+			var err error
+			if path, err = config.DefaultPath(); err != nil {
+				return nil, err
+			}
+		}
+		return config.Load(path)
+	}
+
+	// activeProfile is the profile picked with --profile or PLAYLIST_DOWNLOAD_PROFILE
+	activeProfile := func() string {
+		if profile != "" {
+			return profile
+		}
+		return os.Getenv(config.ProfileEnv)
+	}
+
+	// applyConfig layers the settings: config file, then profile, then environment, then the
+	// flags given on the command line. Credentials end up in their environment variables.
+	applyConfig := func(cmd *cobra.Command) error {
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		settings, err := cfg.Resolve(activeProfile())
+		if err != nil {
+			return err
+		}
+		for _, s := range settings {
+			if s.Source == "" || s.Source == "env" && s.Key.Flag == "" {
+				continue
+			}
+			if s.Key.Flag == "" {
+				if err := os.Setenv(s.Key.Env, s.Value); err != nil {
+					return err
+				}
+				continue
+			}
+			f := cmd.Flags().Lookup(s.Key.Flag)
+			if f == nil || f.Changed {
+				continue
+			}
+			if err := f.Value.Set(s.Value); err != nil {
+				return fmt.Errorf("invalid %s '%s' (from %s): %w", s.Key.Name, s.Value, s.Source, err)
+			}
+		}
+		return nil
+	}
+
+	// writeReport prints or saves the dry-run report, if the run was a dry run
+	writeReport := func(opts downloader.Options) error {
+		if !opts.DryRun {
+			return nil
+		}
+		if reportPath != "" {
+			if err := opts.Report.WriteFile(reportPath); err != nil {
+				return err
+			}
+			fmt.Fprintln(humanOut, "Dry-run report written to", reportPath)
+			return nil
+		}
+		return opts.Report.Print(humanOut)
+	}
+
+	// buildOptions validates the flags shared by every command
+	buildOptions := func() (downloader.Options, error) {
+		format, err := events.ParseOutputFormat(outputFormat)
+		if err != nil {
+			return downloader.Options{}, err
+		}
+		var emitter *events.Emitter
+		if format == events.OutputJSON {
+			emitter = events.New(os.Stdout)
+			humanOut = os.Stderr
+		}
+
+		finalDir, err := utils.EnsureDefaultOutputDir(outputDir)
+		if err != nil {
+			return downloader.Options{}, err
+		}
+
+		browserEnum, err := downloader.ParseBrowserCookieMode(cookies)
+		if err != nil {
+			return downloader.Options{}, err
+		}
+
+		backend, err := yt.ParseSearchBackend(searchBackend)
+		if err != nil {
+			return downloader.Options{}, err
+		}
+		searcher, err := yt.NewSearcher(ctx, backend)
+		if err != nil {
+			return downloader.Options{}, err
+		}
+		isrcMode, err := yt.ParseISRCSearch(isrcSearch)
+		if err != nil {
+			return downloader.Options{}, err
+		}
+
+		audio, err := downloader.ParseAudioFormat(audioFormat)
+		if err != nil {
+			return downloader.Options{}, err
+		}
+
+		template, err := naming.Parse(nameTemplate)
+		if err != nil {
+			return downloader.Options{}, err
+		}
+		collisionRule, err := downloader.ParseCollisionRule(collision)
+		if err != nil {
+			return downloader.Options{}, err
+		}
+
+		formats, err := playlistfile.ParseFormats(playlistFormats)
+		if err != nil {
+			return downloader.Options{}, err
+		}
+
+		cover, err := downloader.ParseCoverMode(coverMode)
+		if err != nil {
+			return downloader.Options{}, err
+		}
+		coverDir, err := covers.DefaultDir()
+		if err != nil {
+			// Covers are still cached in memory for the run
+			log.Printf("Cover cache disabled on disk: %v", err)
+		}
+
+		var lyricsClient *lyrics.Client
+		if withLyrics || lrcSidecar {
+			lyricsClient = lyrics.NewClient(lyricsURL)
+		}
+
+		trackOverrides, err := overrides.Load(overridesPath, finalDir)
+		if err != nil {
+			return downloader.Options{}, err
+		}
+		if trackOverrides.Len() > 0 {
+			fmt.Fprintf(humanOut, "Loaded %d overrides from %s.\n", trackOverrides.Len(), trackOverrides.Path)
+		}
+
+		return downloader.Options{
+			OutputDir:       finalDir,
+			Workers:         workerCount,
+			Cookies:         browserEnum,
+			Format:          audio,
+			Template:        template,
+			Collision:       collisionRule,
+			Searcher:        searcher,
+			ISRCSearch:      isrcMode,
+			Overrides:       trackOverrides,
+			PlaylistFormats: formats,
+			Cover:           cover,
+			Covers:          covers.NewCache(coverDir),
+			Lyrics:          lyricsClient,
+			LyricsSidecar:   lrcSidecar,
+			Events:          emitter,
+		}, nil
+	}
 
 	rootCmd := &cobra.Command{
-		Use: "playlist-download",
+		Use: "playlist-download [flags] [spotify_url...]",
+		Example: `  playlist-download -c Brave -o "./music" -w 5 https://open.spotify.com/track/...
+  playlist-download --cookies Brave --output "./my_playlist" --workers 2 https://open.spotify.com/playlist/...
+  playlist-download https://open.spotify.com/album/...
+  playlist-download --album-types album,compilation https://open.spotify.com/artist/...
+  playlist-download --search ytdlp https://open.spotify.com/album/...
+  playlist-download --format opus https://open.spotify.com/album/...
+  playlist-download --template "{album_artist}/{year} - {album}/{disc}-{track:02} {title}" https://open.spotify.com/playlist/...
+  playlist-download --dry-run --report matches.csv https://open.spotify.com/playlist/...
+  playlist-download -o "./music" https://open.spotify.com/playlist/... https://open.spotify.com/album/...
+  cat urls.txt | playlist-download --input-file - -o "./music"`,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return applyConfig(cmd)
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if len(args) < 1 {
+			urls := args
+			if inputFile != "" {
+				listed, err := readInputFile(inputFile)
+				if err != nil {
+					return err
+				}
+				urls = append(urls, listed...)
+			}
+			if len(urls) < 1 {
 				return cmd.Help()
 			}
 
-			spotifyURL := args[0]
+			spotifyURL := urls[0]
 			if spotifyURL == "" {
 				fmt.Println("=> Spotify URL is required.")
 				return cmd.Help()
 			}
 
-			finalDir, err := utils.EnsureDefaultOutputDir(outputDir)
+			opts, err := buildOptions()
 			if err != nil {
 				return err
 			}
-			outputDir = finalDir
 
-			browserEnum, err := downloader.ParseBrowserCookieMode(cookies)
-			if err != nil {
-				return err
+			// Several URLs share one worker pool, each one in its own folder
+			if len(urls) > 1 {
+				types, err := downloader.ParseAlbumTypes(albumTypes)
+				if err != nil {
+					return err
+				}
+				client, err := auth.InitClient(ctx)
+				if err != nil {
+					return fmt.Errorf("authentication error: %w", err)
+				}
+				if dryRun {
+					opts.DryRun = true
+					opts.Report = report.New()
+				}
+				artist := downloader.ArtistOptions{AlbumTypes: types, TopTracks: topTracks, Market: market}
+				downloadErr := downloader.DownloadSources(ctx, client, urls, artist, opts)
+				return errors.Join(writeReport(opts), downloadErr)
 			}
 
 			urlType, spotifyID, err := parser.ParseSpotifyURL(spotifyURL)
@@ -54,35 +327,506 @@ func main() {
 				return fmt.Errorf("error parsing URL: %w", err)
 			}
 
-			client, err := auth.InitSpotifyClient(ctx)
+			client, err := auth.InitClient(ctx)
 			if err != nil {
 				return fmt.Errorf("authentication error: %w", err)
 			}
 
+			if dryRun {
+				opts.DryRun = true
+				opts.Report = report.New()
+			}
+
+			var downloadErr error
 			switch urlType {
 			case parser.AlbumURL:
-				if err := downloader.DownloadAlbum(ctx, client, spotifyID, outputDir, workerCount, browserEnum); err != nil {
-					return err
-				}
+				downloadErr = downloader.DownloadAlbum(ctx, client, spotifyID, opts)
 			case parser.PlaylistURL:
-				if err := downloader.DownloadPlaylist(ctx, client, spotifyID, outputDir, workerCount, browserEnum); err != nil {
-					return err
-				}
+				downloadErr = downloader.DownloadPlaylist(ctx, client, spotifyID, opts)
 			case parser.TrackURL:
-				if err := downloader.DownloadTrack(ctx, client, spotifyID, outputDir, workerCount, browserEnum); err != nil {
+				downloadErr = downloader.DownloadTrack(ctx, client, spotifyID, opts)
+			case parser.ArtistURL:
+				if topTracks {
+					downloadErr = downloader.DownloadArtistTopTracks(ctx, client, spotifyID, market, opts)
+					break
+				}
+				types, err := downloader.ParseAlbumTypes(albumTypes)
+				if err != nil {
 					return err
 				}
+				downloadErr = downloader.DownloadArtist(ctx, client, spotifyID, types, market, opts)
 			default:
-				fmt.Println("=> Only album, playlist, or track URLs are supported.")
+				fmt.Println("=> Only album, playlist, track or artist URLs are supported.")
+				return cmd.Help()
+			}
+
+			// The report is useful even when some tracks found no match
+			if err := writeReport(opts); err != nil {
+				return err
+			}
+
+			return downloadErr
+		},
+	}
+
+	rootCmd.Flags().StringVarP(
+		&inputFile,
+		"input-file",
+		"i",
+		"",
+		"File with one Spotify URL per line, \"-\" for stdin; empty lines and # comments are ignored",
+	)
+
+	rootCmd.Flags().StringVar(
+		&albumTypes,
+		"album-types",
+		"album,single",
+		"For artist URLs, the release types to download: album, single, compilation, appears_on",
+	)
+
+	rootCmd.Flags().BoolVar(
+		&topTracks,
+		"top-tracks",
+		false,
+		"For artist URLs, download only the artist's top tracks instead of the discography",
+	)
+
+	rootCmd.Flags().StringVar(
+		&market,
+		"market",
+		downloader.DefaultMarket,
+		"Country code used to pick the artist releases and top tracks",
+	)
+
+	rootCmd.Flags().BoolVarP(
+		&dryRun,
+		"dry-run",
+		"n",
+		false,
+		"Resolve every track to a YouTube video and print the matches without downloading anything",
+	)
+
+	rootCmd.Flags().StringVar(
+		&reportPath,
+		"report",
+		"",
+		"With --dry-run, write the report to this file: .json, .csv or plain text (default is stdout)",
+	)
+
+	syncCmd := &cobra.Command{
+		Use:   "sync [flags] [spotify_playlist_url]",
+		Short: "Mirror a Spotify playlist into the output directory",
+		Example: `  playlist-download sync -o "./my_playlist" https://open.spotify.com/playlist/...
+  playlist-download sync --remove trash --output "./my_playlist" https://open.spotify.com/playlist/...`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) < 1 {
+				return cmd.Help()
+			}
+
+			opts, err := buildOptions()
+			if err != nil {
+				return err
+			}
+
+			removalMode, err := downloader.ParseRemovalMode(removal)
+			if err != nil {
+				return err
+			}
+
+			urlType, spotifyID, err := parser.ParseSpotifyURL(args[0])
+			if err != nil {
+				return fmt.Errorf("error parsing URL: %w", err)
+			}
+			if urlType != parser.PlaylistURL {
+				fmt.Println("=> Only playlist URLs can be synced.")
+				return cmd.Help()
+			}
+
+			client, err := auth.InitClient(ctx)
+			if err != nil {
+				return fmt.Errorf("authentication error: %w", err)
+			}
+
+			return downloader.SyncPlaylist(ctx, client, spotifyID, removalMode, opts)
+		},
+	}
+
+	syncCmd.Flags().StringVarP(
+		&removal,
+		"remove",
+		"r",
+		"",
+		"What to do with tracks removed from the playlist: keep, trash or delete (default is keep)",
+	)
+
+	rootCmd.AddCommand(syncCmd)
+
+	loginCmd := &cobra.Command{
+		Use:   "login",
+		Short: "Log in to Spotify to access private playlists, Liked Songs and saved albums",
+		Long: `Opens the Spotify authorization page and saves the token in the user config directory.
+The redirect URL (default http://127.0.0.1:8888/callback, or SPOTIFY_REDIRECT_URL)
+must be registered among the Redirect URIs of your Spotify app.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := auth.DefaultUserConfig()
+			if err != nil {
+				return err
+			}
+			if _, err := auth.Login(ctx, cfg); err != nil {
+				return fmt.Errorf("login failed: %w", err)
+			}
+			fmt.Println("=> Logged in. Token saved to", cfg.TokenFile)
+			return nil
+		},
+	}
+
+	rootCmd.AddCommand(loginCmd)
+
+	retryCmd := &cobra.Command{
+		Use:   "retry-failed",
+		Short: "Download again the tracks that failed in a previous run",
+		Long: `Reads the failed.json files written by previous runs into the output directory
+(and its album subfolders) and downloads only those tracks. No Spotify credentials
+are needed: the track details are stored in the file.`,
+		Example: `  playlist-download retry-failed -o "./my_playlist"
+  playlist-download retry-failed --search ytdlp --cookies Brave -o "./my_playlist"`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts, err := buildOptions()
+			if err != nil {
+				return err
+			}
+			return downloader.RetryFailed(ctx, opts)
+		},
+	}
+
+	rootCmd.AddCommand(retryCmd)
+
+	csvCmd := &cobra.Command{
+		Use:   "from-csv [flags] file.csv...",
+		Short: "Download the tracks listed in CSV files, e.g. Exportify exports, without Spotify credentials",
+		Long: `Reads the tracks from CSV files with a header line, like the playlists exported by
+Exportify, and downloads them without Spotify credentials. Every file is a playlist
+named after the file: a single file is downloaded into the output directory, several
+files each into their own folder.
+Columns are found by their Exportify names (Track Name, Artist Name(s), Album Name,
+Duration (ms), ISRC, ...) or usual ones (Title, Artist, Album, Duration); other names
+are mapped with --columns. Fields: title and artist (required), album, album_artist,
+release_date, duration (3:45 or seconds), duration_ms, isrc, id, album_id,
+track_number, disc_number, image.
+With --spotify the tracks without a Spotify ID are looked up on Spotify, by ISRC when
+the file has one, for the full metadata.`,
+		Example: `  playlist-download from-csv -o "./my_playlist" my_playlist.csv
+  playlist-download from-csv --columns "title=Song,artist=Performer,duration=Length" tracks.csv
+  playlist-download from-csv --dry-run --report matches.csv my_playlist.csv`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) < 1 {
+				return cmd.Help()
+			}
+
+			columns, err := importer.ParseColumns(csvColumns)
+			if err != nil {
+				return err
+			}
+			opts, err := buildOptions()
+			if err != nil {
+				return err
+			}
+
+			var client *spotify.Client
+			if enrich {
+				if client, err = auth.InitClient(ctx); err != nil {
+					return fmt.Errorf("authentication error: %w", err)
+				}
+			}
+
+			var lists []downloader.TrackList
+			for _, path := range args {
+				tracks, err := importer.LoadCSV(path, columns)
+				if err != nil {
+					return err
+				}
+				if client != nil {
+					if tracks, err = downloader.EnrichTracks(ctx, client, tracks, opts); err != nil {
+						return err
+					}
+				}
+				name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+				lists = append(lists, downloader.TrackList{Name: name, Tracks: tracks})
+			}
+
+			if dryRun {
+				opts.DryRun = true
+				opts.Report = report.New()
+			}
+			downloadErr := downloader.DownloadTrackLists(ctx, lists, opts)
+			return errors.Join(writeReport(opts), downloadErr)
+		},
+	}
+
+	csvCmd.Flags().StringVar(
+		&csvColumns,
+		"columns",
+		"",
+		"Columns of the track fields, for CSV files not in the Exportify format, e.g. \"title=Song,artist=Performer,duration=Length\"",
+	)
+
+	csvCmd.Flags().BoolVar(
+		&enrich,
+		"spotify",
+		false,
+		"Look the tracks without a Spotify ID up on Spotify, by ISRC or by artist and title, for the full metadata; needs the Spotify credentials",
+	)
+
+	csvCmd.Flags().BoolVarP(
+		&dryRun,
+		"dry-run",
+		"n",
+		false,
+		"Resolve every track to a YouTube video and print the matches without downloading anything",
+	)
+
+	csvCmd.Flags().StringVar(
+		&reportPath,
+		"report",
+		"",
+		"With --dry-run, write the report to this file: .json, .csv or plain text (default is stdout)",
+	)
+
+	rootCmd.AddCommand(csvCmd)
+
+	textCmd := &cobra.Command{
+		Use:   "from-text [flags] tracklist.txt...",
+		Short: "Download a pasted track list, one \"Artist - Title\" per line",
+		Long: `Reads a track list, one track per line, from text files or from stdin ("-"), e.g.
+  Artist - Title
+  Artist – Title (feat. X)
+  01. Artist - Title [3:45]
+Positions, start times and durations around the track are recognized; empty lines
+and # comments are ignored. Every file is a playlist named after the file: a single
+file is downloaded into the output directory, several files each into their own folder.
+With --spotify every track is looked up on Spotify first, for the album, cover and ISRC.`,
+		Example: `  playlist-download from-text -o "./setlist" setlist.txt
+  pbpaste | playlist-download from-text --spotify -o "./radio" -
+  playlist-download from-text --dry-run --report matches.csv setlist.txt`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) < 1 {
 				return cmd.Help()
 			}
 
+			opts, err := buildOptions()
+			if err != nil {
+				return err
+			}
+
+			var client *spotify.Client
+			if enrich {
+				if client, err = auth.InitClient(ctx); err != nil {
+					return fmt.Errorf("authentication error: %w", err)
+				}
+			}
+
+			var lists []downloader.TrackList
+			for _, path := range args {
+				tracks, err := importer.LoadText(path)
+				if err != nil {
+					return err
+				}
+				if client != nil {
+					if tracks, err = downloader.EnrichTracks(ctx, client, tracks, opts); err != nil {
+						return err
+					}
+				}
+				name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+				if path == "-" {
+					name = "tracklist"
+				}
+				lists = append(lists, downloader.TrackList{Name: name, Tracks: tracks})
+			}
+
+			if dryRun {
+				opts.DryRun = true
+				opts.Report = report.New()
+			}
+			downloadErr := downloader.DownloadTrackLists(ctx, lists, opts)
+			return errors.Join(writeReport(opts), downloadErr)
+		},
+	}
+
+	textCmd.Flags().BoolVar(
+		&enrich,
+		"spotify",
+		false,
+		"Look every track up on Spotify for the full metadata (album, cover, ISRC); needs the Spotify credentials",
+	)
+
+	textCmd.Flags().BoolVarP(
+		&dryRun,
+		"dry-run",
+		"n",
+		false,
+		"Resolve every track to a YouTube video and print the matches without downloading anything",
+	)
+
+	textCmd.Flags().StringVar(
+		&reportPath,
+		"report",
+		"",
+		"With --dry-run, write the report to this file: .json, .csv or plain text (default is stdout)",
+	)
+
+	rootCmd.AddCommand(textCmd)
+
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "Show or change the settings of the config file",
+		Long: `Shows every setting, its value and where it comes from.
+Settings are layered: config file, then the selected profile, then environment
+variables, then command line flags. The config file is
+~/.config/playlist-download/config.yaml (or $XDG_CONFIG_HOME/playlist-download/config.yaml).`,
+		Example: `  playlist-download config set spotify_client_id 0123456789abcdef
+  playlist-download config set output ~/Music
+  playlist-download config set --profile car format mp3
+  playlist-download --profile car https://open.spotify.com/playlist/...`,
+		// The settings are shown as they are, not applied to the flags
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 			return nil
 		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			settings, err := cfg.Resolve(activeProfile())
+			if err != nil {
+				return err
+			}
+
+			status := ""
+			if _, err := os.Stat(cfg.Path); err != nil {
+				status = " (not created yet)"
+			}
+			fmt.Printf("Config file: %s%s\n", cfg.Path, status)
+			if names := cfg.ProfileNames(); len(names) > 0 {
+				fmt.Printf("Profiles: %s\n", strings.Join(names, ", "))
+			}
+			if p := activeProfile(); p != "" {
+				fmt.Printf("Active profile: %s\n", p)
+			}
+			fmt.Println()
+
+			tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "KEY\tVALUE\tSOURCE\tENV")
+			for _, k := range config.Keys {
+				s := settings[k.Name]
+				value, source := s.Value, s.Source
+				if k.Secret && value != "" {
+					value = config.Mask(value)
+				}
+				if source == "" {
+					source = "default"
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", k.Name, value, source, k.Env)
+			}
+			return tw.Flush()
+		},
 	}
 
+	configCmd.AddCommand(&cobra.Command{
+		Use:   "get <key>",
+		Short: "Print the value of a setting, after applying the profile and the environment",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			key, ok := config.LookupKey(args[0])
+			if !ok {
+				return fmt.Errorf("unknown config key '%s'", args[0])
+			}
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			settings, err := cfg.Resolve(activeProfile())
+			if err != nil {
+				return err
+			}
+			fmt.Println(settings[key.Name].Value)
+			return nil
+		},
+	})
+
+	configCmd.AddCommand(&cobra.Command{
+		Use:   "set <key> <value>",
+		Short: "Save a setting in the config file, in the --profile one if given",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			if err := cfg.Set(profile, args[0], args[1]); err != nil {
+				return err
+			}
+			if err := cfg.Save(); err != nil {
+				return err
+			}
+			fmt.Println("=> Saved to", cfg.Path)
+			return nil
+		},
+	})
+
+	configCmd.AddCommand(&cobra.Command{
+		Use:   "unset <key>",
+		Short: "Remove a setting from the config file, from the --profile one if given",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			if err := cfg.Unset(profile, args[0]); err != nil {
+				return err
+			}
+			if err := cfg.Save(); err != nil {
+				return err
+			}
+			fmt.Println("=> Saved to", cfg.Path)
+			return nil
+		},
+	})
+
+	rootCmd.AddCommand(configCmd)
+
+	// newLibraryCmd builds the commands downloading from the library of the logged in user
+	newLibraryCmd := func(use string, short string, download func(context.Context, *spotify.Client, downloader.Options) error) *cobra.Command {
+		libraryCmd := &cobra.Command{
+			Use:   use,
+			Short: short,
+			Long:  short + ".\nRequires a previous 'playlist-download login'.",
+			RunE: func(cmd *cobra.Command, args []string) error {
+				opts, err := buildOptions()
+				if err != nil {
+					return err
+				}
+
+				cfg, err := auth.DefaultUserConfig()
+				if err != nil {
+					return err
+				}
+				client, err := auth.InitUserClient(ctx, cfg)
+				if err != nil {
+					return fmt.Errorf("authentication error: %w", err)
+				}
+
+				return download(ctx, client, opts)
+			},
+		}
+		return libraryCmd
+	}
+
+	rootCmd.AddCommand(newLibraryCmd("liked", "Download your Liked Songs", downloader.DownloadLikedSongs))
+	rootCmd.AddCommand(newLibraryCmd("saved-albums", "Download the albums saved in your library", downloader.DownloadSavedAlbums))
+
 	// Flag -o / --output
-	rootCmd.Flags().StringVarP(
+	rootCmd.PersistentFlags().StringVarP(
 		&outputDir,
 		"output",
 		"o",
@@ -90,48 +834,159 @@ func main() {
 		"Specify the output directory (default is current directory)",
 	)
 
-	rootCmd.Flags().IntVarP(
+	rootCmd.PersistentFlags().IntVarP(
 		&workerCount,
 		"workers",
 		"w",
 		3, // default
-		"Number of concurrent workers (default is 3)",
+		"Number of concurrent workers",
 	)
 
-	rootCmd.Flags().StringVarP(
+	rootCmd.PersistentFlags().StringVarP(
 		&cookies,
 		"cookies",
 		"c",
 		"",
-		"Specify a browser where you are logged in to YouTube."+
-			"it is used to take cookies"+
-			"It is necessary for download age restricted content or similar (default is empty)"+
+		"Specify a browser where you are logged in to YouTube. "+
+			"It is used to take cookies. "+
+			"It is necessary for download age restricted content or similar (default is empty). "+
 			"Currently supported browsers: Chrome, Firefox, Safari, Edge, Brave, Opera",
 	)
 
-	rootCmd.SetUsageTemplate(`
-		Usage:
-		  playlist-download [flags] [spotify_url]
-		
-		Examples:
-		  playlist-download -c Brave -o "./music" -w 5 https://open.spotify.com/track/...
-		  playlist-download --cookies Brave --output "./my_playlist" --workers 2 https://open.spotify.com/playlist/...
-		  playlist-download https://open.spotify.com/album/...
-		
-		Flags:
-		  -o, --output string    Specify the output directory (default is current directory)
-		  -w, --workers int      Number of concurrent workers (default is 3)
-          -c, --cookies string   Specify a browser where you are logged in to YouTube. It is used to take cookies. It is necessary for download age restricted content or similar (default is empty)
-		  -h, --help             Help for this command
-	`)
+	rootCmd.PersistentFlags().StringVarP(
+		&searchBackend,
+		"search",
+		"s",
+		"auto",
+		"YouTube search backend: api (YouTube Data API), ytdlp (no API key needed) "+
+			"or auto (API, switching to yt-dlp when the key is missing or the quota runs out)",
+	)
+
+	rootCmd.PersistentFlags().StringVar(
+		&isrcSearch,
+		"isrc-search",
+		"auto",
+		"Search the ISRC of a track before its title: auto (only with yt-dlp, or once the API quota has run out, "+
+			"since on the Data API it can double the searches), always or never",
+	)
+
+	rootCmd.PersistentFlags().StringVar(
+		&overridesPath,
+		"overrides",
+		"",
+		"YAML or JSON file mapping Spotify track IDs or ISRCs to a YouTube video ID or \"skip\" "+
+			"(default is overrides.yaml/.yml/.json in the output directory)",
+	)
+
+	rootCmd.PersistentFlags().StringVarP(
+		&audioFormat,
+		"format",
+		"f",
+		"mp3",
+		"Output audio format: mp3, opus, m4a (aac), vorbis (ogg) or flac. "+
+			"opus and m4a keep the original YouTube stream when possible",
+	)
+
+	rootCmd.PersistentFlags().StringVarP(
+		&nameTemplate,
+		"template",
+		"t",
+		naming.DefaultTemplate,
+		"File name template, \"/\" separates folders, e.g. \"{album_artist}/{year} - {album}/{disc}-{track:02} {title}\". "+
+			"Fields: title, artist, artists, album, album_artist, album_artists, album_type, year, date, disc, track, "+
+			"total_tracks, isrc, id, album_id, index",
+	)
+
+	rootCmd.PersistentFlags().StringVar(
+		&collision,
+		"on-collision",
+		"number",
+		"What to do when two tracks get the same file name: number (append \" (2)\"), skip or overwrite",
+	)
+
+	rootCmd.PersistentFlags().StringVar(
+		&playlistFormats,
+		"playlist-file",
+		"m3u8",
+		"Playlist files written next to a downloaded playlist, comma separated: m3u8, xspf, pls or none",
+	)
+
+	rootCmd.PersistentFlags().StringVar(
+		&coverMode,
+		"cover",
+		"album",
+		"Cover art embedded into the tracks of a playlist: album (each track's own album art) or playlist (the playlist image)",
+	)
+
+	rootCmd.PersistentFlags().BoolVar(
+		&withLyrics,
+		"lyrics",
+		false,
+		"Embed the lyrics of each track, plain (USLT) and synced (SYLT), from an LRCLIB-compatible API",
+	)
+
+	rootCmd.PersistentFlags().StringVar(
+		&lyricsURL,
+		"lyrics-url",
+		lyrics.DefaultBaseURL,
+		"Base URL of the LRCLIB-compatible lyrics API",
+	)
+
+	rootCmd.PersistentFlags().BoolVar(
+		&lrcSidecar,
+		"lrc",
+		false,
+		"Also write the synced lyrics to a .lrc file next to each track (implies --lyrics)",
+	)
+
+	rootCmd.PersistentFlags().StringVar(
+		&configPath,
+		"config",
+		"",
+		"Config file (default is ~/.config/playlist-download/config.yaml, or PLAYLIST_DOWNLOAD_CONFIG)",
+	)
+
+	rootCmd.PersistentFlags().StringVar(
+		&profile,
+		"profile",
+		"",
+		"Named profile of the config file to use (default is PLAYLIST_DOWNLOAD_PROFILE, or none)",
+	)
+
+	rootCmd.PersistentFlags().StringVar(
+		&outputFormat,
+		"output-format",
+		"text",
+		"Output format: text (logs and progress view) or json (one JSON event per line on stdout, logs on stderr)",
+	)
+
+	// Shared by every command: the flag lists come from the flag definitions
+	rootCmd.SetUsageTemplate(usageTemplate)
 
 	if err := rootCmd.Execute(); err != nil {
-		if strings.Contains(err.Error(), "quotaExceeded") {
-			fmt.Println("Warning: YouTube quota exceeded. Some tracks not downloaded.")
+		if errors.Is(err, context.Canceled) {
+			os.Exit(130)
+		}
+		var failedErr *downloader.TracksFailedError
+		if (errors.As(err, &failedErr) && failedErr.Has(downloader.FailureQuota)) || strings.Contains(err.Error(), "quotaExceeded") {
+			fmt.Fprintln(humanOut, "Warning: YouTube quota exceeded. Some tracks not downloaded (try --search ytdlp).")
 			os.Exit(0)
 		} else {
-			fmt.Println("Error:", err)
+			fmt.Fprintln(humanOut, "Error:", err)
 			os.Exit(1)
 		}
 	}
 }
+
+// readInputFile reads the URLs listed in path, or in stdin for "-".
+func readInputFile(path string) ([]string, error) {
+	if path == "-" {
+		return parser.ReadURLList(os.Stdin)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open input file: %w", err)
+	}
+	defer f.Close()
+	return parser.ReadURLList(f)
+}
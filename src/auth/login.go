@@ -0,0 +1,318 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/zmb3/spotify/v2"
+	spotifyauth "github.com/zmb3/spotify/v2/auth"
+	"golang.org/x/oauth2"
+)
+
+// DefaultRedirectURL must be registered among the Redirect URIs of the Spotify app.
+const DefaultRedirectURL = "http://127.0.0.1:8888/callback"
+
+// UserScopes are the permissions needed to read private and collaborative playlists,
+// Liked Songs and saved albums.
+var UserScopes = []string{
+	spotifyauth.ScopePlaylistReadPrivate,
+	spotifyauth.ScopePlaylistReadCollaborative,
+	spotifyauth.ScopeUserLibraryRead,
+}
+
+// DefaultLoginTimeout is how long Login waits for the user to complete the authorization.
+const DefaultLoginTimeout = 5 * time.Minute
+
+// ErrNotLoggedIn is returned when no user token has been saved yet.
+var ErrNotLoggedIn = errors.New("not logged in to Spotify: run 'playlist-download login' first")
+
+// UserConfig configures the authorization code + PKCE flow. The endpoints can be
+// pointed to a local server, e.g. a mock token endpoint in tests.
+type UserConfig struct {
+	ClientID    string
+	RedirectURL string
+	AuthURL     string
+	TokenURL    string
+	// TokenFile is where the token is persisted between runs
+	TokenFile string
+	Scopes    []string
+	// OpenURL shows the authorization URL to the user. By default it is printed on stdout.
+	OpenURL func(authURL string) error
+	// Timeout bounds the wait for the callback, DefaultLoginTimeout if zero
+	Timeout time.Duration
+}
+
+// DefaultUserConfig reads the client ID from SPOTIFY_CLIENT_ID and the optional redirect
+// URL from SPOTIFY_REDIRECT_URL; the token is kept in the user config directory.
+func DefaultUserConfig() (UserConfig, error) {
+	clientID := os.Getenv("SPOTIFY_CLIENT_ID")
+	if clientID == "" {
+		return UserConfig{}, errors.New("SPOTIFY_CLIENT_ID not set")
+	}
+
+	redirectURL := os.Getenv("SPOTIFY_REDIRECT_URL")
+	if redirectURL == "" {
+		redirectURL = DefaultRedirectURL
+	}
+
+	tokenFile, err := DefaultTokenFile()
+	if err != nil {
+		return UserConfig{}, err
+	}
+
+	return UserConfig{
+		ClientID:    clientID,
+		RedirectURL: redirectURL,
+		AuthURL:     spotifyauth.AuthURL,
+		TokenURL:    spotifyauth.TokenURL,
+		TokenFile:   tokenFile,
+		Scopes:      UserScopes,
+	}, nil
+}
+
+// DefaultTokenFile returns the path of the saved user token.
+func DefaultTokenFile() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("unable to find the user config directory: %w", err)
+	}
+	return filepath.Join(configDir, "playlist-download", "token.json"), nil
+}
+
+func (c UserConfig) oauth2Config() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:    c.ClientID,
+		RedirectURL: c.RedirectURL,
+		Scopes:      c.Scopes,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  c.AuthURL,
+			TokenURL: c.TokenURL,
+			// PKCE clients have no secret: the client ID goes in the request body
+			AuthStyle: oauth2.AuthStyleInParams,
+		},
+	}
+}
+
+// Login runs the authorization code + PKCE flow: it starts a callback server on the
+// redirect URL, asks the user to open the authorization page, exchanges the code for
+// a token and saves it to cfg.TokenFile.
+func Login(ctx context.Context, cfg UserConfig) (*oauth2.Token, error) {
+	redirect, err := url.Parse(cfg.RedirectURL)
+	if err != nil || redirect.Host == "" {
+		return nil, fmt.Errorf("invalid redirect URL: %s", cfg.RedirectURL)
+	}
+	// http://127.0.0.1:8080 has no path: serve the callback on the root
+	callbackPath := redirect.Path
+	if callbackPath == "" {
+		callbackPath = "/"
+	}
+
+	oauthConfig := cfg.oauth2Config()
+	verifier := oauth2.GenerateVerifier()
+	state, err := randomState()
+	if err != nil {
+		return nil, err
+	}
+
+	listener, err := net.Listen("tcp", redirect.Host)
+	if err != nil {
+		return nil, fmt.Errorf("unable to start the callback server on %s: %w", redirect.Host, err)
+	}
+
+	type result struct {
+		token *oauth2.Token
+		err   error
+	}
+	results := make(chan result, 1)
+	var once sync.Once
+	finish := func(r result) {
+		once.Do(func() { results <- r })
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(callbackPath, func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("state") != state {
+			http.Error(w, "State mismatch", http.StatusBadRequest)
+			return
+		}
+		if authErr := query.Get("error"); authErr != "" {
+			http.Error(w, "Authorization failed: "+authErr, http.StatusForbidden)
+			finish(result{err: fmt.Errorf("authorization failed: %s", authErr)})
+			return
+		}
+
+		token, err := oauthConfig.Exchange(r.Context(), query.Get("code"), oauth2.VerifierOption(verifier))
+		if err != nil {
+			http.Error(w, "Unable to retrieve token", http.StatusInternalServerError)
+			finish(result{err: fmt.Errorf("unable to retrieve token: %w", err)})
+			return
+		}
+
+		fmt.Fprintln(w, "Login completed, you can close this window.")
+		finish(result{token: token})
+	})
+
+	server := &http.Server{Handler: mux}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			finish(result{err: fmt.Errorf("callback server error: %w", err)})
+		}
+	}()
+	defer func() {
+		if cErr := server.Close(); cErr != nil {
+			log.Printf("error closing callback server: %v", cErr)
+		}
+	}()
+
+	authURL := oauthConfig.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier))
+	openURL := cfg.OpenURL
+	if openURL == nil {
+		openURL = func(authURL string) error {
+			fmt.Println("=> Open this URL in your browser to log in to Spotify:")
+			fmt.Println(authURL)
+			return nil
+		}
+	}
+	if err := openURL(authURL); err != nil {
+		return nil, err
+	}
+
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = DefaultLoginTimeout
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-timer.C:
+		return nil, fmt.Errorf("login timed out after %s waiting for the authorization callback", timeout)
+	case r := <-results:
+		if r.err != nil {
+			return nil, r.err
+		}
+		if err := saveToken(cfg.TokenFile, r.token); err != nil {
+			return nil, err
+		}
+		return r.token, nil
+	}
+}
+
+// InitUserClient returns a Spotify client acting on behalf of the logged in user.
+// The access token is refreshed when it expires and the new one is saved to cfg.TokenFile.
+func InitUserClient(ctx context.Context, cfg UserConfig) (*spotify.Client, error) {
+	token, err := loadToken(cfg.TokenFile)
+	if err != nil {
+		return nil, err
+	}
+
+	client := spotify.New(oauth2.NewClient(ctx, userTokenSource(ctx, cfg, token)))
+
+	log.Println("=> Successfully authenticated with Spotify as user.")
+	return client, nil
+}
+
+// InitClient prefers the logged in user, which can read private playlists, and falls back
+// to the client credentials of InitSpotifyClient when nobody has logged in.
+func InitClient(ctx context.Context) (*spotify.Client, error) {
+	cfg, err := DefaultUserConfig()
+	if err == nil {
+		client, err := InitUserClient(ctx, cfg)
+		if err == nil {
+			return client, nil
+		}
+		if !errors.Is(err, ErrNotLoggedIn) {
+			return nil, err
+		}
+	}
+	return InitSpotifyClient(ctx)
+}
+
+// userTokenSource returns token, refreshed through cfg.TokenURL once it expires.
+func userTokenSource(ctx context.Context, cfg UserConfig, token *oauth2.Token) oauth2.TokenSource {
+	return &persistingTokenSource{
+		base: oauth2.ReuseTokenSource(token, cfg.oauth2Config().TokenSource(ctx, token)),
+		path: cfg.TokenFile,
+		last: token.AccessToken,
+	}
+}
+
+// persistingTokenSource saves every refreshed token, so the next run starts from it.
+type persistingTokenSource struct {
+	base oauth2.TokenSource
+	path string
+
+	mu   sync.Mutex
+	last string
+}
+
+func (p *persistingTokenSource) Token() (*oauth2.Token, error) {
+	token, err := p.base.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if token.AccessToken != p.last {
+		p.last = token.AccessToken
+		if err := saveToken(p.path, token); err != nil {
+			log.Printf("Error saving refreshed Spotify token: %v", err)
+		}
+	}
+	return token, nil
+}
+
+func loadToken(path string) (*oauth2.Token, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotLoggedIn
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token file: %w", err)
+	}
+
+	var token oauth2.Token
+	if err := json.Unmarshal(data, &token); err != nil {
+		return nil, fmt.Errorf("failed to parse token file %s: %w", path, err)
+	}
+	return &token, nil
+}
+
+func saveToken(path string, token *oauth2.Token) error {
+	data, err := json.MarshalIndent(token, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode token: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create token directory: %w", err)
+	}
+	// The token gives access to the user's account: keep it private
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write token file: %w", err)
+	}
+	return nil
+}
+
+func randomState() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("unable to generate state: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,277 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// tokenServer plays the Spotify token endpoint: it checks the PKCE verifier of the code
+// exchange against the challenge and hands out numbered access tokens.
+type tokenServer struct {
+	challenge string
+
+	mu       sync.Mutex
+	requests []url.Values
+}
+
+func (s *tokenServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	s.requests = append(s.requests, r.PostForm)
+	n := len(s.requests)
+	challenge := s.challenge
+	s.mu.Unlock()
+
+	if r.PostForm.Get("client_id") != "client-id" {
+		http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+		return
+	}
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if r.PostForm.Get("code") != "the-code" || base64.RawURLEncoding.EncodeToString(sum[:]) != challenge {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+	case "refresh_token":
+		if r.PostForm.Get("refresh_token") != "refresh" {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, `{"error":"unsupported_grant_type"}`, http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"access_token":"access-%d","token_type":"Bearer","refresh_token":"refresh","expires_in":3600}`, n)
+}
+
+func (s *tokenServer) grants() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var grants []string
+	for _, r := range s.requests {
+		grants = append(grants, r.Get("grant_type"))
+	}
+	return grants
+}
+
+// freeRedirectURL returns a callback URL on a free local port.
+func freeRedirectURL(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to find a free port: %v", err)
+	}
+	addr := listener.Addr().String()
+	if err := listener.Close(); err != nil {
+		t.Fatalf("unable to release the port: %v", err)
+	}
+	return "http://" + addr + "/callback"
+}
+
+func testConfig(t *testing.T, tokens *tokenServer) UserConfig {
+	server := httptest.NewServer(tokens)
+	t.Cleanup(server.Close)
+	return UserConfig{
+		ClientID:    "client-id",
+		RedirectURL: freeRedirectURL(t),
+		AuthURL:     server.URL + "/authorize",
+		TokenURL:    server.URL + "/api/token",
+		TokenFile:   filepath.Join(t.TempDir(), "playlist-download", "token.json"),
+		Scopes:      UserScopes,
+		Timeout:     10 * time.Second,
+	}
+}
+
+// callback plays the browser coming back from the authorization page.
+func callback(cfg UserConfig, query url.Values) (int, error) {
+	resp, err := http.Get(cfg.RedirectURL + "?" + query.Encode())
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+func TestLogin(t *testing.T) {
+	tokens := &tokenServer{}
+	cfg := testConfig(t, tokens)
+	cfg.OpenURL = func(authURL string) error {
+		parsed, err := url.Parse(authURL)
+		if err != nil {
+			return err
+		}
+		query := parsed.Query()
+		if !strings.HasSuffix(parsed.Path, "/authorize") || query.Get("client_id") != "client-id" ||
+			query.Get("redirect_uri") != cfg.RedirectURL || query.Get("response_type") != "code" {
+			t.Errorf("unexpected authorization URL: %s", authURL)
+		}
+		if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+			t.Errorf("authorization URL without a S256 PKCE challenge: %s", authURL)
+		}
+		if query.Get("scope") != strings.Join(UserScopes, " ") {
+			t.Errorf("scope = %q, want %q", query.Get("scope"), strings.Join(UserScopes, " "))
+		}
+		tokens.mu.Lock()
+		tokens.challenge = query.Get("code_challenge")
+		tokens.mu.Unlock()
+
+		// A forged callback is refused and doesn't end the login
+		if status, err := callback(cfg, url.Values{"code": {"the-code"}, "state": {"forged"}}); err != nil || status != http.StatusBadRequest {
+			t.Errorf("forged callback: status %d, error %v, want 400", status, err)
+		}
+		status, err := callback(cfg, url.Values{"code": {"the-code"}, "state": {query.Get("state")}})
+		if err != nil || status != http.StatusOK {
+			t.Errorf("callback: status %d, error %v, want 200", status, err)
+		}
+		return nil
+	}
+
+	token, err := Login(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if token.AccessToken != "access-1" || token.RefreshToken != "refresh" {
+		t.Errorf("got token %q / %q, want access-1 / refresh", token.AccessToken, token.RefreshToken)
+	}
+
+	info, err := os.Stat(cfg.TokenFile)
+	if err != nil {
+		t.Fatalf("token not saved: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("token file mode = %v, want 0600", info.Mode().Perm())
+	}
+	saved, err := loadToken(cfg.TokenFile)
+	if err != nil || saved.AccessToken != "access-1" {
+		t.Errorf("loadToken = %v, %v, want the access-1 token", saved, err)
+	}
+}
+
+func TestLoginWrongVerifier(t *testing.T) {
+	tokens := &tokenServer{challenge: "not-the-challenge"}
+	cfg := testConfig(t, tokens)
+	cfg.OpenURL = func(authURL string) error {
+		parsed, err := url.Parse(authURL)
+		if err != nil {
+			return err
+		}
+		_, err = callback(cfg, url.Values{"code": {"the-code"}, "state": {parsed.Query().Get("state")}})
+		return err
+	}
+
+	if _, err := Login(context.Background(), cfg); err == nil {
+		t.Fatal("Login succeeded with a verifier not matching the challenge")
+	}
+	if _, err := os.Stat(cfg.TokenFile); !os.IsNotExist(err) {
+		t.Errorf("token file written after a failed exchange: %v", err)
+	}
+}
+
+func TestLoginRedirectWithoutPath(t *testing.T) {
+	tokens := &tokenServer{}
+	cfg := testConfig(t, tokens)
+	cfg.RedirectURL = strings.TrimSuffix(cfg.RedirectURL, "/callback")
+	cfg.OpenURL = func(authURL string) error {
+		parsed, err := url.Parse(authURL)
+		if err != nil {
+			return err
+		}
+		query := parsed.Query()
+		tokens.mu.Lock()
+		tokens.challenge = query.Get("code_challenge")
+		tokens.mu.Unlock()
+		status, err := callback(cfg, url.Values{"code": {"the-code"}, "state": {query.Get("state")}})
+		if err != nil || status != http.StatusOK {
+			t.Errorf("callback: status %d, error %v, want 200", status, err)
+		}
+		return nil
+	}
+
+	token, err := Login(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if token.AccessToken != "access-1" {
+		t.Errorf("got access token %q, want access-1", token.AccessToken)
+	}
+}
+
+func TestLoginTimeout(t *testing.T) {
+	cfg := testConfig(t, &tokenServer{})
+	cfg.Timeout = 50 * time.Millisecond
+	cfg.OpenURL = func(string) error { return nil }
+
+	start := time.Now()
+	_, err := Login(context.Background(), cfg)
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("got error %v, want a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Login returned after %s", elapsed)
+	}
+}
+
+func TestUserTokenSourceRefreshes(t *testing.T) {
+	tokens := &tokenServer{}
+	cfg := testConfig(t, tokens)
+	expired := &oauth2.Token{
+		AccessToken:  "expired",
+		TokenType:    "Bearer",
+		RefreshToken: "refresh",
+		Expiry:       time.Now().Add(-time.Hour),
+	}
+	if err := saveToken(cfg.TokenFile, expired); err != nil {
+		t.Fatalf("saveToken: %v", err)
+	}
+
+	loaded, err := loadToken(cfg.TokenFile)
+	if err != nil {
+		t.Fatalf("loadToken: %v", err)
+	}
+	source := userTokenSource(context.Background(), cfg, loaded)
+	if _, ok := source.(*persistingTokenSource); !ok {
+		t.Fatalf("userTokenSource returned a %T, want a *persistingTokenSource", source)
+	}
+
+	for i := 0; i < 2; i++ {
+		token, err := source.Token()
+		if err != nil {
+			t.Fatalf("Token: %v", err)
+		}
+		if token.AccessToken != "access-1" {
+			t.Errorf("call %d: got token %q, want the refreshed access-1", i, token.AccessToken)
+		}
+	}
+	if grants := tokens.grants(); len(grants) != 1 || grants[0] != "refresh_token" {
+		t.Errorf("token endpoint got %v, want a single refresh_token grant", grants)
+	}
+
+	data, err := os.ReadFile(cfg.TokenFile)
+	if err != nil {
+		t.Fatalf("reading token file: %v", err)
+	}
+	var saved oauth2.Token
+	if err := json.Unmarshal(data, &saved); err != nil || saved.AccessToken != "access-1" {
+		t.Errorf("saved token = %q (%v), want the refreshed access-1", saved.AccessToken, err)
+	}
+}
@@ -0,0 +1,296 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Key is a setting that can be stored in the config file. Settings with a Flag back a
+// command line flag; the others (the credentials) are read from their environment variable.
+type Key struct {
+	Name        string
+	Env         string
+	Flag        string
+	Description string
+	// Secret values are masked when shown
+	Secret bool
+	// Path values may start with "~/"
+	Path bool
+}
+
+// Keys lists every supported setting.
+var Keys = []Key{
+	{Name: "spotify_client_id", Env: "SPOTIFY_CLIENT_ID", Description: "Spotify app client ID", Secret: true},
+	{Name: "spotify_client_secret", Env: "SPOTIFY_CLIENT_SECRET", Description: "Spotify app client secret", Secret: true},
+	{Name: "spotify_redirect_url", Env: "SPOTIFY_REDIRECT_URL", Description: "redirect URL used by login"},
+	{Name: "youtube_api_key", Env: "YOUTUBE_API_KEY", Description: "YouTube Data API key", Secret: true},
+	{Name: "output", Env: "PLAYLIST_DOWNLOAD_OUTPUT", Flag: "output", Description: "default output directory", Path: true},
+	{Name: "workers", Env: "PLAYLIST_DOWNLOAD_WORKERS", Flag: "workers", Description: "number of concurrent workers"},
+	{Name: "format", Env: "PLAYLIST_DOWNLOAD_FORMAT", Flag: "format", Description: "audio format"},
+	{Name: "template", Env: "PLAYLIST_DOWNLOAD_TEMPLATE", Flag: "template", Description: "file name template"},
+	{Name: "cookies", Env: "PLAYLIST_DOWNLOAD_COOKIES", Flag: "cookies", Description: "browser to take the YouTube cookies from"},
+	{Name: "search", Env: "PLAYLIST_DOWNLOAD_SEARCH", Flag: "search", Description: "YouTube search backend"},
+	{Name: "isrc_search", Env: "PLAYLIST_DOWNLOAD_ISRC_SEARCH", Flag: "isrc-search", Description: "when to search the ISRC of a track"},
+	{Name: "on_collision", Env: "PLAYLIST_DOWNLOAD_ON_COLLISION", Flag: "on-collision", Description: "what to do with file name collisions"},
+	{Name: "playlist_file", Env: "PLAYLIST_DOWNLOAD_PLAYLIST_FILE", Flag: "playlist-file", Description: "playlist file formats"},
+	{Name: "cover", Env: "PLAYLIST_DOWNLOAD_COVER", Flag: "cover", Description: "cover art of playlist tracks"},
+	{Name: "lyrics", Env: "PLAYLIST_DOWNLOAD_LYRICS", Flag: "lyrics", Description: "embed lyrics (true/false)"},
+	{Name: "lyrics_url", Env: "PLAYLIST_DOWNLOAD_LYRICS_URL", Flag: "lyrics-url", Description: "LRCLIB-compatible lyrics API"},
+}
+
+// ProfileEnv selects the profile when --profile is not given.
+const ProfileEnv = "PLAYLIST_DOWNLOAD_PROFILE"
+
+// PathEnv points to a config file other than the default one.
+const PathEnv = "PLAYLIST_DOWNLOAD_CONFIG"
+
+// LookupKey returns the setting called name.
+func LookupKey(name string) (Key, bool) {
+	name = strings.ReplaceAll(strings.ToLower(name), "-", "_")
+	for _, k := range Keys {
+		if k.Name == name {
+			return k, true
+		}
+	}
+	return Key{}, false
+}
+
+// DefaultPath returns $XDG_CONFIG_HOME/playlist-download/config.yaml, where XDG_CONFIG_HOME
+// defaults to ~/.config, or the file set in PLAYLIST_DOWNLOAD_CONFIG.
+func DefaultPath() (string, error) {
+	if path := os.Getenv(PathEnv); path != "" {
+		return path, nil
+	}
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("unable to find the home directory: %w", err)
+		}
+		configHome = filepath.Join(home, ".config")
+	}
+	return filepath.Join(configHome, "playlist-download", "config.yaml"), nil
+}
+
+// Config is the content of the config file: top level settings, plus named profiles
+// overriding some of them, for example:
+//
+//	output: ~/Music
+//	workers: 4
+//	profiles:
+//	  car:
+//	    format: mp3
+//	    template: "{artist} - {title}"
+type Config struct {
+	Path     string                       `yaml:"-"`
+	Values   map[string]string            `yaml:",inline"`
+	Profiles map[string]map[string]string `yaml:"profiles,omitempty"`
+}
+
+// Load reads the config file at path. A missing file yields an empty config.
+func Load(path string) (*Config, error) {
+	cfg := &Config{Path: path, Values: map[string]string{}, Profiles: map[string]map[string]string{}}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	if cfg.Values == nil {
+		cfg.Values = map[string]string{}
+	}
+	if cfg.Profiles == nil {
+		cfg.Profiles = map[string]map[string]string{}
+	}
+
+	if err := checkKeys(cfg.Values, path); err != nil {
+		return nil, err
+	}
+	for name, values := range cfg.Profiles {
+		if err := checkKeys(values, fmt.Sprintf("%s (profile %s)", path, name)); err != nil {
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
+
+func checkKeys(values map[string]string, where string) error {
+	for name := range values {
+		if _, ok := LookupKey(name); !ok {
+			return fmt.Errorf("unknown config key '%s' in %s (valid: %s)", name, where, keyNames())
+		}
+	}
+	return nil
+}
+
+// Setting is the value of a key once the layers are resolved.
+type Setting struct {
+	Key   Key
+	Value string
+	// Source is where the value comes from: "env", "profile <name>", "file" or "" if unset
+	Source string
+}
+
+// Resolve layers the settings: the top level of the file, then profile (if not empty),
+// then the environment variables. Flags given on the command line win over all of them
+// and are applied by the caller.
+func (c *Config) Resolve(profile string) (map[string]Setting, error) {
+	var profileValues map[string]string
+	if profile != "" {
+		var ok bool
+		if profileValues, ok = c.Profiles[profile]; !ok {
+			return nil, fmt.Errorf("unknown profile '%s' in %s (available: %s)", profile, c.Path, c.profileNames())
+		}
+	}
+
+	settings := make(map[string]Setting, len(Keys))
+	for _, k := range Keys {
+		s := Setting{Key: k}
+		if v, ok := lookup(c.Values, k.Name); ok {
+			s.Value, s.Source = v, "file"
+		}
+		if v, ok := lookup(profileValues, k.Name); ok {
+			s.Value, s.Source = v, "profile "+profile
+		}
+		if v := os.Getenv(k.Env); v != "" {
+			s.Value, s.Source = v, "env"
+		}
+		if k.Path {
+			s.Value = expandHome(s.Value)
+		}
+		settings[k.Name] = s
+	}
+	return settings, nil
+}
+
+// Set stores value for key, at the top level or in profile.
+func (c *Config) Set(profile string, name string, value string) error {
+	k, ok := LookupKey(name)
+	if !ok {
+		return fmt.Errorf("unknown config key '%s' (valid: %s)", name, keyNames())
+	}
+	values := c.Values
+	if profile != "" {
+		if c.Profiles[profile] == nil {
+			c.Profiles[profile] = map[string]string{}
+		}
+		values = c.Profiles[profile]
+	}
+	// One spelling per key: drop the ones written by hand with dashes or capitals
+	for existing := range values {
+		if other, _ := LookupKey(existing); other.Name == k.Name {
+			delete(values, existing)
+		}
+	}
+	values[k.Name] = value
+	return nil
+}
+
+// Unset removes key from the top level or from profile. An emptied profile is removed.
+func (c *Config) Unset(profile string, name string) error {
+	k, ok := LookupKey(name)
+	if !ok {
+		return fmt.Errorf("unknown config key '%s' (valid: %s)", name, keyNames())
+	}
+	values := c.Values
+	if profile != "" {
+		if values, ok = c.Profiles[profile]; !ok {
+			return fmt.Errorf("unknown profile '%s' in %s (available: %s)", profile, c.Path, c.profileNames())
+		}
+	}
+	for existing := range values {
+		if other, _ := LookupKey(existing); other.Name == k.Name {
+			delete(values, existing)
+		}
+	}
+	if profile != "" && len(values) == 0 {
+		delete(c.Profiles, profile)
+	}
+	return nil
+}
+
+// Save writes the config file. It may hold credentials, so only the user can read it.
+func (c *Config) Save() error {
+	if err := os.MkdirAll(filepath.Dir(c.Path), 0700); err != nil {
+		return fmt.Errorf("error creating config directory: %w", err)
+	}
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("failed to encode config file: %w", err)
+	}
+	if err := os.WriteFile(c.Path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write config file %s: %w", c.Path, err)
+	}
+	// WriteFile keeps the mode of an existing file, e.g. one created by hand
+	if err := os.Chmod(c.Path, 0600); err != nil {
+		return fmt.Errorf("failed to restrict config file %s: %w", c.Path, err)
+	}
+	return nil
+}
+
+// ProfileNames returns the names of the profiles, sorted.
+func (c *Config) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (c *Config) profileNames() string {
+	names := c.ProfileNames()
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ", ")
+}
+
+// Mask hides most of a secret value.
+func Mask(value string) string {
+	if len(value) <= 4 {
+		return strings.Repeat("*", len(value))
+	}
+	return value[:4] + strings.Repeat("*", len(value)-4)
+}
+
+// expandHome replaces a leading "~" with the home directory.
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~"))
+}
+
+// lookup finds name in values, also when written with dashes or capitals.
+func lookup(values map[string]string, name string) (string, bool) {
+	for key, v := range values {
+		if k, ok := LookupKey(key); ok && k.Name == name {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+func keyNames() string {
+	names := make([]string, 0, len(Keys))
+	for _, k := range Keys {
+		names = append(names, k.Name)
+	}
+	return strings.Join(names, ", ")
+}
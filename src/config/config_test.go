@@ -0,0 +1,201 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestResolve(t *testing.T) {
+	path := writeConfig(t, `
+workers: "2"
+format: opus
+Lyrics-URL: https://lyrics.example
+output: ~/Music
+profiles:
+  car:
+    format: mp3
+    template: "{artist} - {title}"
+`)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		profile string
+		env     map[string]string
+		want    map[string]Setting
+	}{
+		{
+			name: "file",
+			want: map[string]Setting{
+				"workers":    {Value: "2", Source: "file"},
+				"format":     {Value: "opus", Source: "file"},
+				"lyrics_url": {Value: "https://lyrics.example", Source: "file"},
+				"output":     {Value: filepath.Join(home, "Music"), Source: "file"},
+				"template":   {},
+			},
+		},
+		{
+			name:    "profile over file",
+			profile: "car",
+			want: map[string]Setting{
+				"workers":  {Value: "2", Source: "file"},
+				"format":   {Value: "mp3", Source: "profile car"},
+				"template": {Value: "{artist} - {title}", Source: "profile car"},
+			},
+		},
+		{
+			name:    "env over profile",
+			profile: "car",
+			env:     map[string]string{"PLAYLIST_DOWNLOAD_FORMAT": "flac", "PLAYLIST_DOWNLOAD_COVER": "playlist"},
+			want: map[string]Setting{
+				"workers": {Value: "2", Source: "file"},
+				"format":  {Value: "flac", Source: "env"},
+				"cover":   {Value: "playlist", Source: "env"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, k := range Keys {
+				t.Setenv(k.Env, tt.env[k.Env])
+			}
+			settings, err := cfg.Resolve(tt.profile)
+			if err != nil {
+				t.Fatalf("Resolve(%q): %v", tt.profile, err)
+			}
+			for name, want := range tt.want {
+				got := settings[name]
+				if got.Value != want.Value || got.Source != want.Source {
+					t.Errorf("%s = %q from %q, want %q from %q", name, got.Value, got.Source, want.Value, want.Source)
+				}
+			}
+		})
+	}
+
+	if _, err := cfg.Resolve("bike"); err == nil || !strings.Contains(err.Error(), "unknown profile 'bike'") {
+		t.Errorf("Resolve of an unknown profile: error = %v", err)
+	}
+}
+
+func TestLoadUnknownKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{name: "top level", content: "colour: blue\n", wantErr: "unknown config key 'colour'"},
+		{name: "profile", content: "profiles:\n  car:\n    speed: fast\n", wantErr: "(profile car)"},
+		{name: "not yaml", content: "workers: [\n", wantErr: "failed to parse config file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Load(writeConfig(t, tt.content)); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Load error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	cfg, err := Load(filepath.Join(t.TempDir(), "missing.yaml"))
+	if err != nil || len(cfg.Values) != 0 || len(cfg.Profiles) != 0 {
+		t.Errorf("Load of a missing file = %+v, %v, want an empty config", cfg, err)
+	}
+	if err := cfg.Set("", "colour", "blue"); err == nil || !strings.Contains(err.Error(), "unknown config key 'colour'") {
+		t.Errorf("Set of an unknown key: error = %v", err)
+	}
+	if err := cfg.Unset("", "colour"); err == nil || !strings.Contains(err.Error(), "unknown config key 'colour'") {
+		t.Errorf("Unset of an unknown key: error = %v", err)
+	}
+}
+
+func TestSetUnsetSave(t *testing.T) {
+	// Written by hand: another spelling of a key, and readable by everyone
+	path := writeConfig(t, "Workers: \"8\"\nprofiles:\n  car:\n    format: mp3\n")
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if err := cfg.Set("", "workers", "4"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := cfg.Set("car", "on-collision", "skip"); err != nil {
+		t.Fatalf("Set in a profile: %v", err)
+	}
+	if err := cfg.Set("bike", "format", "opus"); err != nil {
+		t.Fatalf("Set in a new profile: %v", err)
+	}
+	if err := cfg.Unset("car", "format"); err != nil {
+		t.Fatalf("Unset: %v", err)
+	}
+	if err := cfg.Unset("bike", "format"); err != nil {
+		t.Fatalf("Unset the last key of a profile: %v", err)
+	}
+	if err := cfg.Unset("boat", "format"); err == nil {
+		t.Error("Unset in an unknown profile succeeded")
+	}
+	if err := cfg.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("config file mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	saved, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load after Save: %v", err)
+	}
+	if want := map[string]string{"workers": "4"}; !reflect.DeepEqual(saved.Values, want) {
+		t.Errorf("top level = %v, want %v", saved.Values, want)
+	}
+	want := map[string]map[string]string{"car": {"on_collision": "skip"}}
+	if !reflect.DeepEqual(saved.Profiles, want) {
+		t.Errorf("profiles = %v, want %v", saved.Profiles, want)
+	}
+}
+
+func TestSaveCreatesPrivateFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "playlist-download", "config.yaml")
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if err := cfg.Set("", "youtube_api_key", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("config file mode = %v, want 0600", info.Mode().Perm())
+	}
+}
@@ -0,0 +1,118 @@
+package covers
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"playlist-download/src/utils"
+	"sync"
+	"time"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+// Cache keeps the album covers in memory and on disk, keyed by album ID, so every album's
+// art is downloaded once per run and once across runs. It is safe for concurrent use:
+// workers asking for the same album wait for a single download.
+// A nil *Cache downloads every cover without caching it.
+type Cache struct {
+	dir string
+
+	mu      sync.Mutex
+	entries map[string]*entry
+}
+
+type entry struct {
+	once sync.Once
+	data []byte
+}
+
+// DefaultDir returns the on-disk cache folder, inside the user cache directory.
+func DefaultDir() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("unable to find the user cache directory: %w", err)
+	}
+	return filepath.Join(cacheDir, "playlist-download", "covers"), nil
+}
+
+// NewCache returns a cache storing the covers in dir. An empty dir keeps them in memory only.
+func NewCache(dir string) *Cache {
+	return &Cache{dir: dir, entries: make(map[string]*entry)}
+}
+
+// Get returns the cover of album, or nil if it has none or it can't be downloaded.
+func (c *Cache) Get(album spotify.SimpleAlbum) []byte {
+	if len(album.Images) == 0 {
+		return nil
+	}
+	if c == nil {
+		return download(album)
+	}
+
+	key := cacheKey(album)
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	if !ok {
+		e = &entry{}
+		c.entries[key] = e
+	}
+	c.mu.Unlock()
+
+	e.once.Do(func() {
+		e.data = c.load(key, album)
+	})
+	return e.data
+}
+
+func (c *Cache) load(key string, album spotify.SimpleAlbum) []byte {
+	var path string
+	if c.dir != "" {
+		path = filepath.Join(c.dir, key)
+		if data, err := os.ReadFile(path); err == nil && len(data) > 0 {
+			return data
+		}
+	}
+
+	data := download(album)
+	if data == nil || path == "" {
+		return data
+	}
+
+	// The disk cache is only an optimization: errors just mean downloading again next time
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		log.Printf("Error creating cover cache directory: %v", err)
+		return data
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		log.Printf("Error caching cover of album %s: %v", album.Name, err)
+		return data
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		log.Printf("Error caching cover of album %s: %v", album.Name, err)
+	}
+	return data
+}
+
+func download(album spotify.SimpleAlbum) []byte {
+	// Spotify lists the images largest first
+	coverArt, err := utils.DownloadFileWithRetry(album.Images[0].URL, 3, 2*time.Second)
+	if err != nil {
+		log.Printf("Error downloading album art for album %s: %v", album.Name, err)
+		return nil
+	}
+	return coverArt
+}
+
+// cacheKey is the album ID; albums without one (e.g. built from other sources) use the image URL.
+func cacheKey(album spotify.SimpleAlbum) string {
+	if album.ID != "" {
+		return string(album.ID)
+	}
+	sum := sha1.Sum([]byte(album.Images[0].URL))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,213 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"playlist-download/src/utils"
+	"strings"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+// DefaultMarket is the market used for artist releases and top tracks. Without a market
+// Spotify returns one copy of each release per country it is available in.
+const DefaultMarket = "US"
+
+// ParseAlbumTypes parses a comma separated list of release types (album, single,
+// compilation, appears_on) into the filter used by DownloadArtist.
+func ParseAlbumTypes(input string) ([]spotify.AlbumType, error) {
+	var albumTypes []spotify.AlbumType
+	for _, part := range strings.Split(strings.ToLower(input), ",") {
+		switch strings.TrimSpace(part) {
+		case "":
+			continue
+		case "album":
+			albumTypes = append(albumTypes, spotify.AlbumTypeAlbum)
+		case "single":
+			albumTypes = append(albumTypes, spotify.AlbumTypeSingle)
+		case "compilation":
+			albumTypes = append(albumTypes, spotify.AlbumTypeCompilation)
+		case "appears_on":
+			albumTypes = append(albumTypes, spotify.AlbumTypeAppearsOn)
+		default:
+			return nil, fmt.Errorf("invalid album type: '%s' (valid: album, single, compilation, appears_on)", part)
+		}
+	}
+	if len(albumTypes) == 0 {
+		return nil, errors.New("at least one album type is required")
+	}
+	return albumTypes, nil
+}
+
+// DownloadArtist downloads the artist's releases of the given types, each one into its own
+// album folder inside opts.OutputDir. A track released more than once (e.g. as a single
+// and then on an album) is downloaded only with the first release it appears on.
+func DownloadArtist(ctx context.Context, client *spotify.Client, artistID string, albumTypes []spotify.AlbumType, market string, opts Options) error {
+	batches, fetchErr := artistBatches(ctx, client, artistID, albumTypes, market, opts)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if len(batches) == 0 {
+		return fetchErr
+	}
+	// A release that could not be fetched doesn't stop the others
+	return errors.Join(downloadBatches(ctx, batches, opts), fetchErr)
+}
+
+// artistBatches returns a batch per release of the artist, going into its album folder.
+// With a template building its own folders every release goes into a single batch.
+// The error reports the releases that could not be fetched.
+func artistBatches(ctx context.Context, client *spotify.Client, artistID string, albumTypes []spotify.AlbumType, market string, opts Options) ([]*batch, error) {
+	albums, err := fetchArtistAlbums(ctx, client, artistID, albumTypes, market)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(opts.out(), "Found %d releases.\n", len(albums))
+
+	seen := make(map[string]bool)
+	var batches []*batch
+	// The batch of every album folder: releases with the same name share it, even when
+	// they aren't listed one after the other
+	folderBatches := make(map[string]*batch)
+	var finalErr error
+	for _, simpleAlbum := range albums {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		album, err := client.GetAlbum(ctx, simpleAlbum.ID, spotify.Market(market))
+		if err != nil {
+			log.Printf("Error fetching album %s: %v", simpleAlbum.Name, err)
+			finalErr = err
+			continue
+		}
+
+		tracks, err := fetchFullTracks(ctx, client, album, market)
+		if err != nil {
+			log.Printf("Error fetching tracks of album %s: %v", album.Name, err)
+			finalErr = err
+			continue
+		}
+
+		var trackList []spotify.FullTrack
+		for _, track := range tracks {
+			key := dedupeKey(track)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			trackList = append(trackList, track)
+		}
+		if len(trackList) == 0 {
+			log.Printf("Skipping album %s: all its tracks were already found on other releases", album.Name)
+			continue
+		}
+
+		fmt.Fprintf(opts.out(), "=> %s (%s)\n", album.Name, album.AlbumType)
+		albumOpts := opts
+		albumOpts.OutputDir = albumOutputDir(opts, album.Name)
+
+		// Releases sharing the folder share the batch, so their file names can't collide
+		if b, ok := folderBatches[albumOpts.OutputDir]; ok {
+			b.tracks = append(b.tracks, trackList...)
+			continue
+		}
+		b := newBatch(album.Name, trackList, nil, albumOpts)
+		folderBatches[albumOpts.OutputDir] = b
+		batches = append(batches, b)
+	}
+
+	return batches, finalErr
+}
+
+// DownloadArtistTopTracks downloads the artist's most popular tracks in market.
+func DownloadArtistTopTracks(ctx context.Context, client *spotify.Client, artistID string, market string, opts Options) error {
+	trackList, err := client.GetArtistsTopTracks(ctx, spotify.ID(artistID), market)
+	if err != nil {
+		return fmt.Errorf("failed to fetch artist top tracks: %w", err)
+	}
+
+	// Top tracks come from different albums: each one gets its own album's cover
+	return DownloadTrackList(ctx, client, trackList, nil, opts)
+}
+
+func fetchArtistAlbums(ctx context.Context, client *spotify.Client, artistID string, albumTypes []spotify.AlbumType, market string) ([]spotify.SimpleAlbum, error) {
+	page, err := client.GetArtistAlbums(ctx, spotify.ID(artistID), albumTypes, spotify.Market(market), spotify.Limit(50))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch artist albums: %w", err)
+	}
+
+	albums := page.Albums
+
+	// Pagination
+	for {
+		err := client.NextPage(ctx, page)
+		if err != nil {
+			if err.Error() == spotify.ErrNoMorePages.Error() {
+				break
+			}
+			return nil, fmt.Errorf("error paginating artist albums: %w", err)
+		}
+		albums = append(albums, page.Albums...)
+	}
+
+	return albums, nil
+}
+
+// fetchFullTracks returns every track of album as a full track, which unlike the
+// simplified album tracks carries the ISRC used to spot the same song on other releases.
+// An empty market leaves the choice to Spotify (the user's country for user clients).
+func fetchFullTracks(ctx context.Context, client *spotify.Client, album *spotify.FullAlbum, market string) ([]spotify.FullTrack, error) {
+	simpleTracks := album.Tracks.Tracks
+	page := &album.Tracks
+	for {
+		err := client.NextPage(ctx, page)
+		if err != nil {
+			if err.Error() == spotify.ErrNoMorePages.Error() {
+				break
+			}
+			return nil, fmt.Errorf("error paginating album tracks: %w", err)
+		}
+		simpleTracks = append(simpleTracks, page.Tracks...)
+	}
+
+	var tracks []spotify.FullTrack
+	// The tracks endpoint accepts at most 50 IDs per request
+	for start := 0; start < len(simpleTracks); start += 50 {
+		end := min(start+50, len(simpleTracks))
+		var ids []spotify.ID
+		for _, t := range simpleTracks[start:end] {
+			ids = append(ids, t.ID)
+		}
+
+		var requestOpts []spotify.RequestOption
+		if market != "" {
+			requestOpts = append(requestOpts, spotify.Market(market))
+		}
+
+		fullTracks, err := client.GetTracks(ctx, ids, requestOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch tracks: %w", err)
+		}
+		for _, t := range fullTracks {
+			if t != nil {
+				tracks = append(tracks, *t)
+			}
+		}
+	}
+	return tracks, nil
+}
+
+// dedupeKey identifies the same recording across releases: by ISRC when known,
+// otherwise by title, main artist and duration.
+func dedupeKey(track spotify.FullTrack) string {
+	if isrc := utils.TrackISRC(track); isrc != "" {
+		return "isrc:" + strings.ToUpper(isrc)
+	}
+	artist := ""
+	if len(track.Artists) > 0 {
+		artist = track.Artists[0].Name
+	}
+	return fmt.Sprintf("name:%s|%s|%d", strings.ToLower(track.Name), strings.ToLower(artist), int(track.Duration)/1000)
+}
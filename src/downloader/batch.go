@@ -0,0 +1,215 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"playlist-download/src/events"
+	"playlist-download/src/progress"
+	"playlist-download/src/state"
+	"slices"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+// batch is a list of tracks downloaded into the same folder, with its own state file.
+// Several batches can share a single worker pool.
+type batch struct {
+	// name is shown to the user, empty for the only batch of a run
+	name     string
+	tracks   []spotify.FullTrack
+	coverArt []byte
+	// opts are the run options, with OutputDir pointing to the folder of the batch
+	opts  Options
+	store *state.Store
+	paths []string
+	// sharedPaths holds the paths written by more than one track, see resolveTrackPaths
+	sharedPaths map[string]bool
+	// results has a slot per track, filled by the workers
+	results []TrackResult
+	// reuse maps the index of a track to the job, of another batch, downloading the same recording
+	reuse map[int]trackJob
+}
+
+func newBatch(name string, tracks []spotify.FullTrack, coverArt []byte, opts Options) *batch {
+	return &batch{name: name, tracks: tracks, coverArt: coverArt, opts: opts}
+}
+
+// downloadBatches downloads every batch with one pool of opts.Workers workers. A recording
+// found in more than one batch is downloaded only for the first one and then copied into
+// the folders of the others.
+func downloadBatches(ctx context.Context, batches []*batch, opts Options) error {
+	total := 0
+	// Batches sharing a folder share its state file too, or one would overwrite the other
+	stores := make(map[string]*state.Store)
+	for _, b := range batches {
+		total += len(b.tracks)
+		if err := os.MkdirAll(b.opts.OutputDir, 0755); err != nil {
+			return fmt.Errorf("error creating output directory: %w", err)
+		}
+		store, ok := stores[b.opts.OutputDir]
+		if !ok {
+			var err error
+			if store, err = state.Load(b.opts.OutputDir); err != nil {
+				return err
+			}
+			stores[b.opts.OutputDir] = store
+		}
+		b.store = store
+		b.paths, b.sharedPaths = resolveTrackPaths(b.tracks, store, b.opts)
+		b.results = make([]TrackResult, len(b.tracks))
+	}
+	queued := planReuse(batches)
+
+	fmt.Fprintf(opts.out(), "Found %d tracks.\n", total)
+	if reused := total - len(queued); reused > 0 {
+		fmt.Fprintf(opts.out(), "%d tracks appear more than once: they are downloaded once and copied.\n", reused)
+	}
+	fmt.Fprintln(opts.out(), "Searching and downloading tracks with", opts.Workers, "workers...")
+
+	opts.Events.Emit(events.Event{
+		Type:      events.RunStarted,
+		OutputDir: opts.OutputDir,
+		Total:     total,
+		Workers:   opts.Workers,
+		DryRun:    opts.DryRun,
+	})
+
+	// The events replace the progress view: a nil *Progress shows nothing
+	var prog *progress.Progress
+	if !opts.Events.Enabled() {
+		prog = progress.New(os.Stdout, progress.IsTerminal(os.Stdout), len(queued), opts.Workers)
+	}
+	prog.Start()
+
+	// 1. Create the channels
+	jobs := make(chan trackJob, len(queued))
+	results := make(chan TrackResult, len(queued))
+
+	// 2. Start the workers
+	for w := 0; w < opts.Workers; w++ {
+		go workerFunc(ctx, jobs, results, prog.Worker(w))
+	}
+
+	// 3. Send the tracks to the workers
+	for _, job := range queued {
+		opts.Events.Emit(trackEvent(events.TrackQueued, job))
+		jobs <- job
+	}
+	close(jobs)
+
+	// 4. Pick up the results from the workers; they are stored in their batch
+	for range queued {
+		switch r := <-results; r.Status {
+		case ResultInterrupted:
+		case ResultFailed:
+			prog.TrackDone(false)
+		default:
+			prog.TrackDone(true)
+		}
+	}
+	prog.Stop()
+
+	// 5. The tracks found in other batches take the file downloaded for them
+	for _, b := range batches {
+		for index, primary := range b.reuse {
+			b.results[index] = reuseTrack(ctx, b, index, primary)
+		}
+	}
+
+	var all, failed []TrackResult
+	var failedDirs []string
+	finished, interrupted := 0, 0
+	for _, b := range batches {
+		if !opts.DryRun {
+			if err := updateFailedFile(b.opts.OutputDir, b.results); err != nil {
+				log.Printf("Error updating %s: %v", FailedFileName, err)
+			}
+		}
+		for _, r := range b.results {
+			switch r.Status {
+			case ResultFailed:
+				if !slices.Contains(failedDirs, b.opts.OutputDir) {
+					failedDirs = append(failedDirs, b.opts.OutputDir)
+				}
+				failed = append(failed, r)
+			case ResultInterrupted:
+				interrupted++
+			default:
+				finished++
+			}
+		}
+		all = append(all, b.results...)
+	}
+	printSummary(opts.out(), all)
+	opts.Events.Emit(runFinishedEvent(all, ctx.Err()))
+
+	// Interrupted (Ctrl-C): every finished track is already in the state file,
+	// the interrupted ones are left as they were so the next run picks them up
+	if ctx.Err() != nil {
+		for _, store := range stores {
+			if err := store.Save(); err != nil {
+				log.Printf("Error saving state: %v", err)
+			}
+		}
+		fmt.Fprintf(opts.out(), "Interrupted: %d of %d tracks finished, %d failed, %d not processed.\n", finished, total, len(failed), interrupted)
+		fmt.Fprintln(opts.out(), "Run the same command again to resume.")
+		return ctx.Err()
+	}
+
+	if opts.DryRun {
+		fmt.Fprintln(opts.out(), "Dry run complete!")
+	} else {
+		fmt.Fprintln(opts.out(), "Download complete!")
+	}
+	if len(failed) > 0 {
+		if !opts.DryRun {
+			printRetryHint(opts.out(), opts.OutputDir, failedDirs)
+		}
+		return &TracksFailedError{Failed: failed, Total: total}
+	}
+	return nil
+}
+
+// printRetryHint tells where the failed tracks are listed: dirs are the folders, under root,
+// whose failed.json got failed tracks in this run.
+func printRetryHint(w io.Writer, root string, dirs []string) {
+	if len(dirs) == 1 {
+		fmt.Fprintf(w, "Failed tracks are listed in %s: run 'playlist-download retry-failed -o %s' to try them again.\n",
+			filepath.Join(dirs[0], FailedFileName), dirs[0])
+		return
+	}
+	fmt.Fprintf(w, "Failed tracks are listed in the %s of these folders:\n", FailedFileName)
+	for _, dir := range dirs {
+		fmt.Fprintf(w, "  %s\n", dir)
+	}
+	fmt.Fprintf(w, "Run 'playlist-download retry-failed -o %s' to try them again: it also searches the subfolders.\n", root)
+}
+
+// planReuse returns the jobs to run. With several batches, a recording already queued for
+// an earlier batch is not queued again but recorded in the reuse map of its batch.
+func planReuse(batches []*batch) []trackJob {
+	first := make(map[string]trackJob)
+	var queued []trackJob
+	for _, b := range batches {
+		b.reuse = make(map[int]trackJob)
+		for i, track := range b.tracks {
+			job := trackJob{index: i, track: track, path: b.paths[i], batch: b}
+			if len(batches) > 1 {
+				key := dedupeKey(track)
+				if primary, ok := first[key]; ok && primary.batch != b {
+					b.reuse[i] = primary
+					continue
+				}
+				if _, ok := first[key]; !ok {
+					first[key] = job
+				}
+			}
+			queued = append(queued, job)
+		}
+	}
+	return queued
+}
@@ -0,0 +1,48 @@
+package downloader
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPrintRetryHint(t *testing.T) {
+	root := filepath.Join("music", "run")
+	album := filepath.Join(root, "Album")
+	playlist := filepath.Join(root, "Playlist")
+
+	tests := []struct {
+		name string
+		dirs []string
+		want []string
+	}{
+		{
+			name: "output directory",
+			dirs: []string{root},
+			want: []string{filepath.Join(root, FailedFileName), "retry-failed -o " + root + "'"},
+		},
+		{
+			name: "one subfolder",
+			dirs: []string{album},
+			want: []string{filepath.Join(album, FailedFileName), "retry-failed -o " + album + "'"},
+		},
+		{
+			name: "several subfolders",
+			dirs: []string{album, playlist},
+			want: []string{"  " + album + "\n", "  " + playlist + "\n", "retry-failed -o " + root + "'", "subfolders"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			printRetryHint(&out, root, tt.dirs)
+			for _, want := range tt.want {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("hint %q doesn't contain %q", out.String(), want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package downloader
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// partialSuffixRegex matches what yt-dlp and the tagging leave next to an unfinished track
+// "<name>.<ext>": "<name>.webm.part", "<name>.f251.webm", "<name>.temp.mp3",
+// "<name>.tagging.opus", "<name>.webp", ... Complete audio files ("<name>.flac") don't
+// match: they may be another track of the folder downloaded in another format.
+var partialSuffixRegex = regexp.MustCompile(`^(` +
+	// Downloads in progress and the streams of a format merged later
+	`(f\d+\.)?` + mediaExt + `\.(part(-Frag\d+)?|ytdl)|f\d+\.` + mediaExt +
+	// Post-processing and tagging copies
+	`|(temp|tagging)\.` + mediaExt +
+	// Thumbnails and the video containers the audio is extracted from
+	`|webp|jpg|jpeg|png|webm|mkv|mp4` +
+	`)$`)
+
+// mediaExt is a regexp group of the extensions of the files yt-dlp downloads or writes.
+const mediaExt = `(webm|m4a|mp4|mkv|opus|ogg|mp3|flac|aac|mka)`
+
+// removePartialFiles deletes the files of a track whose processing was interrupted, including
+// the final file, which may be incomplete or untagged. Other tracks whose name starts with
+// the same words ("Intro.Remix.mp3" next to "Intro.mp3") are left alone.
+func removePartialFiles(path string) {
+	dir := filepath.Dir(path)
+	prefix := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)) + "."
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasPrefix(e.Name(), prefix) {
+			continue
+		}
+		// The final file of the track itself, which may be incomplete or untagged
+		if e.Name() != filepath.Base(path) && !partialSuffixRegex.MatchString(strings.TrimPrefix(e.Name(), prefix)) {
+			continue
+		}
+		partial := filepath.Join(dir, e.Name())
+		if err := os.Remove(partial); err != nil && !os.IsNotExist(err) {
+			log.Printf("Error removing partial file %s: %v", partial, err)
+		}
+	}
+}
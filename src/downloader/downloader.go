@@ -4,11 +4,24 @@ import (
 	"context"
 	"fmt"
 	"github.com/zmb3/spotify/v2"
+	"io"
 	"log"
+	"os"
 	"path/filepath"
+	"playlist-download/src/covers"
+	"playlist-download/src/events"
+	"playlist-download/src/lyrics"
+	"playlist-download/src/naming"
+	"playlist-download/src/overrides"
+	"playlist-download/src/playlistfile"
+	"playlist-download/src/progress"
+	"playlist-download/src/report"
+	"playlist-download/src/state"
 	"playlist-download/src/tags"
 	"playlist-download/src/utils"
 	yt "playlist-download/src/yt"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -25,6 +38,80 @@ const (
 	Opera           EnumCookies = "opera"
 )
 
+// Options holds the settings shared by every download of a run.
+type Options struct {
+	OutputDir string
+	Workers   int
+	Cookies   EnumCookies
+	Format    AudioFormat
+	// Template names the file of each track; nil means naming.DefaultTemplate
+	Template  *naming.Template
+	Collision CollisionRule
+	// PlaylistFormats are the playlist files written next to a downloaded playlist
+	PlaylistFormats []playlistfile.Format
+	// Cover picks the art embedded into the tracks of a playlist
+	Cover CoverMode
+	// Covers caches the album covers; nil downloads them without caching
+	Covers *covers.Cache
+	// Lyrics fetches the lyrics embedded into the tracks; nil disables them
+	Lyrics *lyrics.Client
+	// LyricsSidecar also writes the synced lyrics to a .lrc file next to each track
+	LyricsSidecar bool
+	// Searcher finds the YouTube video for each track
+	Searcher yt.VideoSearcher
+	// ISRCSearch decides when the ISRC of a track is searched before its title
+	ISRCSearch yt.ISRCSearch
+	// Overrides pins tracks to a fixed YouTube video or skips them; may be nil
+	Overrides *overrides.Overrides
+	// DryRun resolves every track to a YouTube video without downloading it.
+	// The matches are collected in Report.
+	DryRun bool
+	Report *report.Report
+	// Events receives what happens to every track as JSON events; when set, the human
+	// readable output goes to stderr and the progress view is off. May be nil.
+	Events *events.Emitter
+}
+
+// out is where the human readable output goes: stdout, unless it carries the JSON events.
+func (o Options) out() io.Writer {
+	if o.Events.Enabled() {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+type CoverMode string
+
+const (
+	// CoverAlbum embeds each track's own album art
+	CoverAlbum CoverMode = ""
+	// CoverPlaylist embeds the playlist image into every track of a playlist
+	CoverPlaylist CoverMode = "playlist"
+)
+
+func ParseCoverMode(input string) (CoverMode, error) {
+	input = strings.ToLower(input)
+	switch input {
+	case "", "album":
+		return CoverAlbum, nil
+	case "playlist":
+		return CoverPlaylist, nil
+	default:
+		return CoverAlbum, fmt.Errorf("invalid cover mode: '%s' (valid: album, playlist)", input)
+	}
+}
+
+// reportCandidates is how many candidates per track end up in the dry-run report
+const reportCandidates = 5
+
+type trackJob struct {
+	index int
+	track spotify.FullTrack
+	// path is the file of the track, empty when it lost a file name collision
+	path  string
+	batch *batch
+}
+
 func ParseBrowserCookieMode(input string) (EnumCookies, error) {
 	input = strings.ToLower(input)
 	switch input {
@@ -60,27 +147,44 @@ func buildSearchQuery(track spotify.FullTrack) string {
 	return query
 }
 
+func searchTarget(track spotify.FullTrack) yt.Target {
+	var artists []string
+	for _, a := range track.Artists {
+		artists = append(artists, a.Name)
+	}
+	return yt.Target{
+		Title:           track.Name,
+		Artists:         artists,
+		DurationSeconds: int(track.Duration) / 1000,
+		ISRC:            utils.TrackISRC(track),
+	}
+}
+
 func sanitizeFileName(name string) string {
 	name = utils.RemoveIllegalPathChars(name)
 	return name
 }
 
-func downloadTrackWithRetry(videoID string, track spotify.FullTrack, outputDir string, maxRetries int, delay time.Duration, cookies EnumCookies) (string, error) {
+// downloadProgressRegex matches the progress lines printed by yt-dlp with --newline,
+// e.g. "[download]  45.2% of 3.50MiB at 1.20MiB/s ETA 00:01"
+var downloadProgressRegex = regexp.MustCompile(`^\[download\]\s+(\d+(?:\.\d+)?)%`)
+
+// downloadTrackWithRetry downloads the video as the audio file finalPath and returns how many
+// times yt-dlp was run. onProgress, if not nil, gets the download percentage as yt-dlp reports it.
+func downloadTrackWithRetry(ctx context.Context, videoID string, finalPath string, format AudioFormat, maxRetries int, delay time.Duration, cookies EnumCookies, onProgress func(percent float64)) (string, int, error) {
 	ytURL := "https://www.youtube.com/watch?v=" + videoID
-	finalPath := filepath.Join(outputDir, sanitizeFileName(track.Name)+".mp3")
-
-	cmdArgs := []string{
-		"-f", "bestaudio",
-		"--extract-audio",
-		"--audio-format", "mp3",
-		"--audio-quality", "0",
-		"--write-thumbnail",
-		"--embed-thumbnail",
-		"--embed-metadata",
-		"-o", finalPath,
+	// yt-dlp picks the extension: the name must not carry one, or the audio extraction
+	// would read and write the same file
+	outputTemplate := strings.TrimSuffix(finalPath, filepath.Ext(finalPath)) + ".%(ext)s"
 
-		ytURL,
-	}
+	cmdArgs := append(format.ytdlpArgs(),
+		"--embed-metadata",
+		// The file may be there from an older match that was overridden since
+		"--force-overwrites",
+		// One progress line per update instead of a line redrawn in place
+		"--newline",
+		"-o", outputTemplate,
+	)
 
 	if cookies != EnumCookiesNone {
 		cmdArgs = append(cmdArgs, "--cookies-from-browser", string(cookies))
@@ -88,14 +192,33 @@ func downloadTrackWithRetry(videoID string, track spotify.FullTrack, outputDir s
 
 	cmdArgs = append(cmdArgs, ytURL)
 
-	output, err := utils.RunCmdWithRetry("yt-dlp", cmdArgs, maxRetries, delay)
+	var onLine func(string)
+	if onProgress != nil {
+		onLine = func(line string) {
+			if m := downloadProgressRegex.FindStringSubmatch(line); m != nil {
+				if percent, err := strconv.ParseFloat(m[1], 64); err == nil {
+					onProgress(percent)
+				}
+			}
+		}
+	}
+
+	attempts := 0
+	err := utils.RetryContext(ctx, maxRetries, delay, func() error {
+		attempts++
+		_, err := utils.RunCmdLines(ctx, "yt-dlp", cmdArgs, onLine)
+		return err
+	})
+	if ctx.Err() != nil {
+		return "", attempts, ctx.Err()
+	}
 	if err != nil {
-		return "", fmt.Errorf("yt-dlp (retry) failed: %v\nCommand output: %s", err, string(output))
+		return "", attempts, fmt.Errorf("yt-dlp (retry) failed: %w", err)
 	}
-	return finalPath, nil
+	return finalPath, attempts, nil
 }
 
-func DownloadAlbum(ctx context.Context, client *spotify.Client, albumID string, outputDir string, workerCount int, cookies EnumCookies) error {
+func DownloadAlbum(ctx context.Context, client *spotify.Client, albumID string, opts Options) error {
 	album, err := client.GetAlbum(ctx, spotify.ID(albumID))
 	if err != nil {
 		return fmt.Errorf("failed to fetch album: %w", err)
@@ -104,31 +227,39 @@ func DownloadAlbum(ctx context.Context, client *spotify.Client, albumID string,
 		return fmt.Errorf("album %s not found (empty response)", albumID)
 	}
 
-	var trackList []spotify.FullTrack
-	for _, val := range album.Tracks.Tracks {
-		trackList = append(trackList, spotify.FullTrack{
-			SimpleTrack: val,
-			Album:       album.SimpleAlbum,
-		})
+	// Full tracks carry the ISRC; fetchFullTracks also gets the tracks past the first page
+	trackList, err := fetchFullTracks(ctx, client, album, "")
+	if err != nil {
+		return err
 	}
 
-	var coverArt []byte
-	if len(album.Images) > 0 {
-		coverArtURL := album.Images[0].URL
-		coverArt, err = utils.DownloadFileWithRetry(coverArtURL, 3, 2*time.Second)
-		if err != nil {
-			log.Printf("Error downloading album art for album %s: %v", album.Name, err)
-			coverArt = nil
-		}
+	// Every track gets the album cover from opts.Covers
+	return DownloadTrackList(ctx, client, trackList, nil, opts)
+}
+
+func DownloadPlaylist(ctx context.Context, client *spotify.Client, playlistID string, opts Options) error {
+	trackList, err := fetchPlaylistTracks(ctx, client, playlistID)
+	if err != nil {
+		return err
 	}
 
-	return DownloadTrackList(ctx, client, trackList, outputDir, coverArt, workerCount, cookies)
+	// With album art, sharedCoverArt stays nil and every track gets its own album's cover
+	name, coverArt := fetchPlaylistDetails(ctx, client, playlistID, opts.Cover == CoverPlaylist)
+
+	downloadErr := DownloadTrackList(ctx, client, trackList, coverArt, opts)
+
+	// Also with some failed tracks: the playlist file lists the ones that made it
+	if err := writePlaylistFiles(name, trackList, opts); err != nil {
+		log.Printf("Error writing playlist files: %v", err)
+	}
+
+	return downloadErr
 }
 
-func DownloadPlaylist(ctx context.Context, client *spotify.Client, playlistID string, outputDir string, workerCount int, cookies EnumCookies) error {
+func fetchPlaylistTracks(ctx context.Context, client *spotify.Client, playlistID string) ([]spotify.FullTrack, error) {
 	playlistTracks, err := client.GetPlaylistTracks(ctx, spotify.ID(playlistID))
 	if err != nil {
-		return fmt.Errorf("failed to fetch playlist: %w", err)
+		return nil, fmt.Errorf("failed to fetch playlist: %w", err)
 	}
 
 	var trackList []spotify.FullTrack
@@ -143,19 +274,29 @@ func DownloadPlaylist(ctx context.Context, client *spotify.Client, playlistID st
 			if err.Error() == spotify.ErrNoMorePages.Error() {
 				break
 			}
-			return fmt.Errorf("error paginating playlist: %w", err)
+			return nil, fmt.Errorf("error paginating playlist: %w", err)
 		}
 		for _, t := range playlistTracks.Tracks {
 			trackList = append(trackList, t.Track)
 		}
 	}
 
+	return trackList, nil
+}
+
+// fetchPlaylistDetails returns the name and, if withCover, the cover art of the playlist.
+// Both are optional: when the details can't be fetched the name falls back to the playlist ID.
+func fetchPlaylistDetails(ctx context.Context, client *spotify.Client, playlistID string, withCover bool) (string, []byte) {
+	name := playlistID
 	playlist, err := client.GetPlaylist(ctx, spotify.ID(playlistID))
 	if err != nil {
 		log.Printf("Cannot fetch playlist details: %v", err)
 	}
+	if playlist != nil && playlist.Name != "" {
+		name = playlist.Name
+	}
 	var coverArt []byte
-	if playlist != nil && len(playlist.Images) > 0 {
+	if withCover && playlist != nil && len(playlist.Images) > 0 {
 		coverArtURL := playlist.Images[0].URL
 		coverArt, err = utils.DownloadFileWithRetry(coverArtURL, 3, 2*time.Second)
 		if err != nil {
@@ -163,106 +304,312 @@ func DownloadPlaylist(ctx context.Context, client *spotify.Client, playlistID st
 			coverArt = nil
 		}
 	}
-
-	return DownloadTrackList(ctx, client, trackList, outputDir, coverArt, workerCount, cookies)
+	return name, coverArt
 }
 
-func DownloadTrack(ctx context.Context, client *spotify.Client, trackID string, outputDir string, workerCount int, cookies EnumCookies) error {
+func DownloadTrack(ctx context.Context, client *spotify.Client, trackID string, opts Options) error {
 	song, err := client.GetTrack(ctx, spotify.ID(trackID))
 	if err != nil {
 		return fmt.Errorf("failed to fetch track: %w", err)
 	}
 
-	ft := spotify.FullTrack{
-		SimpleTrack: song.SimpleTrack,
-		Album:       song.Album,
-	}
-
-	var coverArt []byte
-	if len(song.Album.Images) > 0 {
-		coverArtURL := song.Album.Images[0].URL
-		coverArt, err = utils.DownloadFile(coverArtURL)
-		if err != nil {
-			log.Printf("Error downloading track cover art: %v", err)
-			coverArt = nil
-		}
-	}
+	// The whole track: its external IDs carry the ISRC
+	ft := *song
 
-	return DownloadTrackList(ctx, client, []spotify.FullTrack{ft}, outputDir, coverArt, workerCount, cookies)
+	return DownloadTrackList(ctx, client, []spotify.FullTrack{ft}, nil, opts)
 }
 
+// DownloadTrackList downloads tracks into opts.OutputDir. sharedCoverArt is embedded into
+// every track; when it is nil each track gets its album cover from opts.Covers.
 func DownloadTrackList(
 	ctx context.Context,
 	client *spotify.Client,
 	tracks []spotify.FullTrack,
-	outputDir string,
 	sharedCoverArt []byte,
-	workerCount int,
-	cookies EnumCookies,
+	opts Options,
 ) error {
-	fmt.Printf("Found %d tracks.\n", len(tracks))
-	fmt.Println("Searching and downloading tracks with", workerCount, "workers...")
-
-	// 1. Create the channels
-	jobs := make(chan spotify.FullTrack, len(tracks))
-	results := make(chan error, len(tracks))
+	return downloadBatches(ctx, []*batch{newBatch("", tracks, sharedCoverArt, opts)}, opts)
+}
 
-	// 2. Start the workers
-	for w := 0; w < workerCount; w++ {
-		go workerFunc(ctx, jobs, results, outputDir, sharedCoverArt, cookies)
+func workerFunc(ctx context.Context, jobs <-chan trackJob, results chan<- TrackResult, worker *progress.Worker) {
+	for job := range jobs {
+		b := job.batch
+		var result TrackResult
+		// After a cancellation the remaining jobs are only drained
+		if ctx.Err() != nil {
+			result = TrackResult{Index: job.index, Track: job.track, Status: ResultInterrupted, Err: ctx.Err()}
+		} else {
+			result = processSingleTrack(ctx, job, b.store, b.coverArt, b.opts, worker)
+			worker.Idle()
+		}
+		// Every job has its own slot: the collector reads them once all results are in
+		b.results[job.index] = result
+		results <- result
 	}
+}
 
-	// 3. Send the tracks to the workers
-	for _, track := range tracks {
-		jobs <- track
+// skipIfAlreadyDownloaded reports whether the track can be skipped because a previous
+// run already produced its file. Files found on disk without a state entry (e.g. from
+// runs made before the state file existed) are adopted into the state, except in dry-run,
+// unless the path is shared with other tracks of the run: then nothing tells whose the
+// file is. A track is downloaded again when an override now points it to a different video.
+func skipIfAlreadyDownloaded(track spotify.FullTrack, path string, shared bool, store *state.Store, override overrides.Override, opts Options) bool {
+	trackID := trackKey(track)
+	if e, ok := store.Get(trackID); ok && override.VideoID != "" && e.VideoID != "" && e.VideoID != override.VideoID {
+		return false
+	}
+	if store.IsDone(trackID) {
+		return true
+	}
+	// A failed track may have left a half-processed file behind: never trust it
+	if e, ok := store.Get(trackID); ok && e.Status == state.StatusFailed {
+		return false
 	}
-	close(jobs)
 
-	// 4. Pick up the results from the workers
-	var finalErr error
-	for i := 0; i < len(tracks); i++ {
-		err := <-results
-		if err != nil {
-			finalErr = err
-		}
+	if _, err := os.Stat(path); err != nil || shared {
+		return false
+	}
+	if opts.DryRun {
+		return true
 	}
 
-	fmt.Println("Download complete!")
-	return finalErr
+	if err := store.Set(state.Entry{TrackID: trackID, Path: path, Status: state.StatusDone}); err != nil {
+		log.Printf("Error saving state for '%s': %v\n", track.Name, err)
+	}
+	return true
 }
 
-func workerFunc(ctx context.Context, jobs <-chan spotify.FullTrack, results chan<- error, outputDir string, coverArt []byte, cookies EnumCookies) {
-	for track := range jobs {
-		err := processSingleTrack(ctx, track, outputDir, coverArt, cookies)
-		results <- err
+func processSingleTrack(ctx context.Context, job trackJob, store *state.Store, coverArt []byte, opts Options, worker *progress.Worker) (result TrackResult) {
+	start := time.Now()
+	track := job.track
+	result = TrackResult{Index: job.index, Track: track, Status: ResultSkipped}
+	// Every way out reports the track once, with how long it took
+	defer func() {
+		result.Duration = time.Since(start)
+		emitTrackResult(opts.Events, job, result)
+	}()
+	entry := newReportEntry(job)
+	override, hasOverride := opts.Overrides.Lookup(track)
+
+	// fail records a failure both in the result and in the state file
+	fail := func(category FailureCategory, err error) TrackResult {
+		if !opts.DryRun {
+			markFailed(store, track, result.VideoID, err)
+		}
+		result.Status = ResultFailed
+		result.Category = category
+		result.Err = err
+		return result
+	}
+	interrupted := func() TrackResult {
+		result.Status = ResultInterrupted
+		result.Err = ctx.Err()
+		return result
 	}
-}
 
-func processSingleTrack(ctx context.Context, track spotify.FullTrack, outputDir string, coverArt []byte, cookies EnumCookies) error {
-	query := buildSearchQuery(track)
-	trackDurationSec := int(track.Duration) / 1000
+	// 0. Skip the tracks excluded by an override or already downloaded by a previous run
+	if hasOverride && override.Skip {
+		log.Printf("Skipping '%s': skipped by override %s\n", track.Name, override.Key)
+		result.Reason = "skipped by override " + override.Key
+		if opts.DryRun {
+			entry.Note = "skipped by override " + override.Key
+			opts.Report.Add(entry)
+		}
+		return result
+	}
+	if job.path == "" {
+		log.Printf("Skipping '%s': its file name collides with another track\n", track.Name)
+		result.Reason = "file name collision"
+		if opts.DryRun {
+			entry.Note = "skipped: file name collision"
+			opts.Report.Add(entry)
+		}
+		return result
+	}
+	if skipIfAlreadyDownloaded(track, job.path, job.batch.sharedPaths[strings.ToLower(job.path)], store, override, opts) {
+		log.Printf("Skipping '%s': already downloaded\n", track.Name)
+		result.Reason = "already downloaded"
+		result.Path = job.path
+		if opts.DryRun {
+			entry.Note = "already downloaded"
+			opts.Report.Add(entry)
+		}
+		return result
+	}
 
-	// 1. Find the YouTube video ID
-	videoID, err := yt.FindClosestMatchingVideo(query, trackDurationSec)
-	if err != nil {
-		log.Printf("Error finding YouTube match for '%s': %v\n", track.Name, err)
-		return err
+	// 1. Find the YouTube video ID: an override wins, otherwise candidates come ranked, best first
+	if hasOverride {
+		result.VideoID = override.VideoID
+		log.Printf("Using override %s for '%s': video %s\n", override.Key, track.Name, result.VideoID)
+		ev := trackEvent(events.MatchChosen, job)
+		ev.VideoID = result.VideoID
+		ev.Source = "override"
+		opts.Events.Emit(ev)
+		if opts.DryRun {
+			entry.Note = "matched by override " + override.Key
+			entry.Candidates = []report.Candidate{{
+				VideoID: result.VideoID,
+				URL:     "https://www.youtube.com/watch?v=" + result.VideoID,
+				Picked:  true,
+				Reason:  "picked: manual override " + override.Key,
+			}}
+			opts.Report.Add(entry)
+			result.Status = ResultDone
+			return result
+		}
+	} else {
+		worker.Phase(trackLabel(track), progress.PhaseSearching)
+
+		// With an ISRC the search tries it first; query is the one the candidates come from
+		query, candidates, err := yt.SearchTrack(ctx, opts.Searcher, buildSearchQuery(track), searchTarget(track), opts.ISRCSearch)
+		entry.Query = query
+		if ctx.Err() != nil {
+			return interrupted()
+		}
+		if err != nil {
+			log.Printf("Error finding YouTube match for '%s': %v\n", track.Name, err)
+			if opts.DryRun {
+				entry.Error = err.Error()
+				opts.Report.Add(entry)
+			}
+			return fail(classifySearchError(err), err)
+		}
+		best := candidates[0]
+		result.VideoID = best.Result.ID
+		log.Printf("Matched '%s' to '%s' (score %.1f)\n", track.Name, best.Result.Title, best.Score)
+		ev := trackEvent(events.MatchChosen, job)
+		ev.VideoID = result.VideoID
+		ev.VideoTitle = best.Result.Title
+		ev.Score = best.Score
+		ev.Source = "search"
+		opts.Events.Emit(ev)
+
+		if opts.DryRun {
+			entry.Candidates = reportCandidatesFor(candidates)
+			opts.Report.Add(entry)
+			result.Status = ResultDone
+			return result
+		}
 	}
+	videoID := result.VideoID
 
-	// 2. Download the track as MP3 using retry
-	fileName, err := downloadTrackWithRetry(videoID, track, outputDir, 3, 2*time.Second, cookies)
+	// 2. Download the track in the chosen format using retry
+	if err := os.MkdirAll(filepath.Dir(job.path), 0755); err != nil {
+		log.Printf("Error creating folder for '%s': %v\n", track.Name, err)
+		return fail(FailureOther, err)
+	}
+	if ctx.Err() != nil {
+		return interrupted()
+	}
+	worker.Phase(trackLabel(track), progress.PhaseDownloading)
+	fileName, attempts, err := downloadTrackWithRetry(ctx, videoID, job.path, opts.Format, 3, 2*time.Second, opts.Cookies, progressReporter(opts.Events, job, worker))
+	result.Attempts = attempts
+	if ctx.Err() != nil {
+		removePartialFiles(job.path)
+		return interrupted()
+	}
 	if err != nil {
 		log.Printf("Error downloading '%s': %v\n", track.Name, err)
-		return err
+		return fail(classifyDownloadError(err), err)
 	}
 
-	// 3. Tag the downloaded MP3 file
-	tagErr := tags.TagFileWithSpotifyMetadata(fileName, track, coverArt)
+	// 3. Tag the downloaded file
+	worker.Phase(trackLabel(track), progress.PhaseTagging)
+	if coverArt == nil {
+		coverArt = opts.Covers.Get(track.Album)
+	}
+	trackLyrics := fetchLyrics(ctx, track, opts)
+	tagErr := tags.TagFile(ctx, fileName, track, coverArt, "https://www.youtube.com/watch?v="+videoID, trackLyrics)
 	if tagErr != nil {
+		// ffmpeg may have been stopped by the same Ctrl-C: an untagged file is a partial one.
+		// A track tagged before the Ctrl-C is complete and kept.
+		if ctx.Err() != nil {
+			removePartialFiles(job.path)
+			return interrupted()
+		}
 		log.Printf("Error tagging '%s': %v\n", track.Name, tagErr)
-		return tagErr
+		return fail(FailureTag, tagErr)
+	}
+	writeLyricsFile(track, fileName, trackLyrics, opts)
+
+	if err := store.Set(state.Entry{
+		TrackID: trackKey(track),
+		VideoID: videoID,
+		Path:    fileName,
+		Status:  state.StatusDone,
+	}); err != nil {
+		log.Printf("Error saving state for '%s': %v\n", track.Name, err)
 	}
 
 	log.Printf("Successfully downloaded and tagged '%s'\n", track.Name)
-	return nil
+	result.Path = fileName
+	result.Status = ResultDone
+	return result
+}
+
+// trackLabel is how a track is shown in the progress view: "Artist - Title".
+func trackLabel(track spotify.FullTrack) string {
+	if len(track.Artists) == 0 {
+		return track.Name
+	}
+	return track.Artists[0].Name + " - " + track.Name
+}
+
+func newReportEntry(job trackJob) report.Entry {
+	var artists []string
+	for _, a := range job.track.Artists {
+		artists = append(artists, a.Name)
+	}
+	return report.Entry{
+		Index:    job.index,
+		TrackID:  string(job.track.ID),
+		Artist:   strings.Join(artists, ", "),
+		Title:    job.track.Name,
+		Duration: yt.FormatVideoDuration(int(job.track.Duration) / 1000),
+	}
+}
+
+// reportCandidatesFor explains why the first candidate was picked and the others were not.
+func reportCandidatesFor(candidates []yt.Candidate) []report.Candidate {
+	var out []report.Candidate
+	for i, c := range candidates {
+		if i == reportCandidates {
+			break
+		}
+
+		reason := "picked: best score"
+		if i > 0 {
+			reason = fmt.Sprintf("rejected: score %.1f below %.1f", c.Score, candidates[0].Score)
+		}
+		reason += "; " + strings.Join(c.Reasons, ", ")
+
+		duration := ""
+		if c.Duration > 0 {
+			duration = yt.FormatVideoDuration(c.Duration)
+		}
+
+		out = append(out, report.Candidate{
+			VideoID:  c.Result.ID,
+			Title:    c.Result.Title,
+			Channel:  c.Result.Uploader,
+			URL:      c.Result.URL,
+			Duration: duration,
+			Score:    c.Score,
+			Picked:   i == 0,
+			Reason:   reason,
+		})
+	}
+	return out
+}
+
+func markFailed(store *state.Store, track spotify.FullTrack, videoID string, cause error) {
+	err := store.Set(state.Entry{
+		TrackID: trackKey(track),
+		VideoID: videoID,
+		Status:  state.StatusFailed,
+		Error:   cause.Error(),
+	})
+	if err != nil {
+		log.Printf("Error saving state for '%s': %v\n", track.Name, err)
+	}
 }
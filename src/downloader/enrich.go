@@ -0,0 +1,105 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"playlist-download/src/utils"
+	yt "playlist-download/src/yt"
+	"strings"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+// EnrichTracks looks the tracks without a Spotify ID up on Spotify, by ISRC when they have
+// one and then by artist and title. The tracks found are replaced by the Spotify ones, with
+// the full metadata (album, cover, ISRC, numbering, release date); the others are kept as
+// they are.
+func EnrichTracks(ctx context.Context, client *spotify.Client, tracks []spotify.FullTrack, opts Options) ([]spotify.FullTrack, error) {
+	enriched := make([]spotify.FullTrack, len(tracks))
+	searched, found := 0, 0
+	for i, track := range tracks {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		enriched[i] = track
+		if track.ID != "" {
+			continue
+		}
+		searched++
+
+		match, err := searchSpotifyTrack(ctx, client, track)
+		if err != nil {
+			log.Printf("Spotify search failed for '%s': %v", trackLabel(track), err)
+			continue
+		}
+		if match == nil {
+			log.Printf("'%s' not found on Spotify, keeping the details of the list", trackLabel(track))
+			continue
+		}
+		enriched[i] = *match
+		found++
+	}
+	fmt.Fprintf(opts.out(), "Found %d of %d tracks on Spotify.\n", found, searched)
+	return enriched, nil
+}
+
+// searchSpotifyTrack returns the Spotify track with the ISRC of track or, failing that,
+// the first search result that is the same song as track. It returns nil if there is none.
+func searchSpotifyTrack(ctx context.Context, client *spotify.Client, track spotify.FullTrack) (*spotify.FullTrack, error) {
+	if isrc := utils.TrackISRC(track); isrc != "" {
+		result, err := client.Search(ctx, "isrc:"+isrc, spotify.SearchTypeTrack, spotify.Limit(5))
+		if err != nil {
+			return nil, err
+		}
+		if result.Tracks != nil {
+			for i := range result.Tracks.Tracks {
+				if strings.EqualFold(utils.TrackISRC(result.Tracks.Tracks[i]), isrc) {
+					return &result.Tracks.Tracks[i], nil
+				}
+			}
+		}
+	}
+
+	query := "track:" + utils.CleanTitleForSearch(track.Name)
+	if len(track.Artists) > 0 {
+		query += " artist:" + track.Artists[0].Name
+	}
+	result, err := client.Search(ctx, query, spotify.SearchTypeTrack, spotify.Limit(10))
+	if err != nil {
+		return nil, err
+	}
+	if result.Tracks == nil {
+		return nil, nil
+	}
+	for i := range result.Tracks.Tracks {
+		if isSameSong(track, result.Tracks.Tracks[i]) {
+			return &result.Tracks.Tracks[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// isSameSong reports whether candidate has the title and the main artist of track and,
+// when the duration of track is known, about the same length.
+func isSameSong(track spotify.FullTrack, candidate spotify.FullTrack) bool {
+	if min(yt.Similarity(track.Name, candidate.Name), yt.Similarity(candidate.Name, track.Name)) < 0.8 {
+		return false
+	}
+	if len(track.Artists) > 0 {
+		var names []string
+		for _, a := range candidate.Artists {
+			names = append(names, a.Name)
+		}
+		if yt.Similarity(track.Artists[0].Name, strings.Join(names, " ")) < 0.8 {
+			return false
+		}
+	}
+	if track.Duration > 0 {
+		diff := int(track.Duration - candidate.Duration)
+		if diff < -10000 || diff > 10000 {
+			return false
+		}
+	}
+	return true
+}
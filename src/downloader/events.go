@@ -0,0 +1,94 @@
+package downloader
+
+import (
+	"math"
+	"playlist-download/src/events"
+	"playlist-download/src/progress"
+	"time"
+)
+
+// trackEvent returns an event of type t about the track of job.
+func trackEvent(t events.Type, job trackJob) events.Event {
+	ev := events.Event{
+		Type:    t,
+		Index:   job.index + 1,
+		TrackID: string(job.track.ID),
+		Title:   job.track.Name,
+	}
+	if len(job.track.Artists) > 0 {
+		ev.Artist = job.track.Artists[0].Name
+	}
+	return ev
+}
+
+// emitTrackResult emits how the track ended. Dry-run matches were already reported by
+// match_chosen and interrupted tracks only count in run_finished.
+func emitTrackResult(emitter *events.Emitter, job trackJob, result TrackResult) {
+	var ev events.Event
+	switch result.Status {
+	case ResultDone:
+		if result.Path == "" {
+			return
+		}
+		ev = trackEvent(events.Tagged, job)
+		ev.Path = result.Path
+	case ResultSkipped:
+		ev = trackEvent(events.Skipped, job)
+		ev.Reason = result.Reason
+	case ResultFailed:
+		ev = trackEvent(events.Failed, job)
+		ev.Category = string(result.Category)
+		if result.Err != nil {
+			ev.Error = result.Err.Error()
+		}
+	default:
+		return
+	}
+	ev.VideoID = result.VideoID
+	ev.Attempts = result.Attempts
+	ev.Seconds = result.Duration.Round(time.Millisecond).Seconds()
+	emitter.Emit(ev)
+}
+
+// progressReporter passes the yt-dlp download percentage to the progress view and, once
+// per whole percent, to the events: yt-dlp reports it many times a second.
+func progressReporter(emitter *events.Emitter, job trackJob, worker *progress.Worker) func(percent float64) {
+	last := -1.0
+	return func(percent float64) {
+		worker.Percent(percent)
+		// A new attempt starts over
+		if percent < last {
+			last = -1
+		}
+		if !emitter.Enabled() || math.Floor(percent) <= last {
+			return
+		}
+		last = math.Floor(percent)
+		ev := trackEvent(events.DownloadProgress, job)
+		ev.Percent = percent
+		emitter.Emit(ev)
+	}
+}
+
+// runFinishedEvent sums up the results of DownloadTrackList. err is the reason the run
+// stopped early, if any.
+func runFinishedEvent(results []TrackResult, err error) events.Event {
+	counts := &events.Counts{}
+	for _, r := range results {
+		switch r.Status {
+		case ResultDone:
+			counts.Done++
+		case ResultSkipped:
+			counts.Skipped++
+		case ResultFailed:
+			counts.Failed++
+		case ResultInterrupted:
+			counts.Interrupted++
+		}
+	}
+	ev := events.Event{Type: events.RunFinished, Total: len(results), Counts: counts}
+	if err != nil {
+		ev.Error = err.Error()
+	}
+	return ev
+}
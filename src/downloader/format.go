@@ -0,0 +1,71 @@
+package downloader
+
+import (
+	"fmt"
+	"strings"
+)
+
+type AudioFormat string
+
+const (
+	FormatMP3    AudioFormat = "mp3"
+	FormatOpus   AudioFormat = "opus"
+	FormatM4A    AudioFormat = "m4a"
+	FormatVorbis AudioFormat = "vorbis"
+	FormatFLAC   AudioFormat = "flac"
+)
+
+func ParseAudioFormat(input string) (AudioFormat, error) {
+	input = strings.ToLower(input)
+	switch input {
+	case "", "mp3":
+		return FormatMP3, nil
+	case "opus":
+		return FormatOpus, nil
+	case "m4a", "aac":
+		return FormatM4A, nil
+	case "vorbis", "ogg":
+		return FormatVorbis, nil
+	case "flac":
+		return FormatFLAC, nil
+	default:
+		return FormatMP3, fmt.Errorf("invalid audio format: '%s' (valid: mp3, opus, m4a, aac, vorbis, flac)", input)
+	}
+}
+
+// Extension returns the file extension (without the dot) of the format.
+func (f AudioFormat) Extension() string {
+	switch f {
+	case FormatVorbis:
+		return "ogg"
+	case "":
+		return string(FormatMP3)
+	default:
+		return string(f)
+	}
+}
+
+// ytdlpArgs returns the yt-dlp format selection and conversion arguments.
+// YouTube serves opus and AAC streams: for opus and m4a the matching stream is preferred,
+// so yt-dlp only remuxes it instead of re-encoding.
+func (f AudioFormat) ytdlpArgs() []string {
+	switch f {
+	case FormatOpus:
+		return []string{"-f", "bestaudio[acodec=opus]/bestaudio", "--extract-audio", "--audio-format", "opus"}
+	case FormatM4A:
+		return []string{"-f", "bestaudio[ext=m4a]/bestaudio", "--extract-audio", "--audio-format", "m4a"}
+	case FormatVorbis:
+		return []string{"-f", "bestaudio", "--extract-audio", "--audio-format", "vorbis", "--audio-quality", "0"}
+	case FormatFLAC:
+		return []string{"-f", "bestaudio", "--extract-audio", "--audio-format", "flac"}
+	default:
+		return []string{
+			"-f", "bestaudio",
+			"--extract-audio",
+			"--audio-format", "mp3",
+			"--audio-quality", "0",
+			"--write-thumbnail",
+			"--embed-thumbnail",
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+// DownloadLikedSongs downloads the Liked Songs of the logged in user.
+// client must be authorized by the user (see auth.Login).
+func DownloadLikedSongs(ctx context.Context, client *spotify.Client, opts Options) error {
+	page, err := client.CurrentUsersTracks(ctx, spotify.Limit(50))
+	if err != nil {
+		return fmt.Errorf("failed to fetch liked songs: %w", err)
+	}
+
+	var trackList []spotify.FullTrack
+	for _, t := range page.Tracks {
+		trackList = append(trackList, t.FullTrack)
+	}
+
+	// Pagination
+	for {
+		err := client.NextPage(ctx, page)
+		if err != nil {
+			if err.Error() == spotify.ErrNoMorePages.Error() {
+				break
+			}
+			return fmt.Errorf("error paginating liked songs: %w", err)
+		}
+		for _, t := range page.Tracks {
+			trackList = append(trackList, t.FullTrack)
+		}
+	}
+
+	// Liked songs come from different albums: each one gets its own album's cover
+	return DownloadTrackList(ctx, client, trackList, nil, opts)
+}
+
+// DownloadSavedAlbums downloads the albums saved in the library of the logged in user,
+// each one into its own album folder. client must be authorized by the user.
+func DownloadSavedAlbums(ctx context.Context, client *spotify.Client, opts Options) error {
+	page, err := client.CurrentUsersAlbums(ctx, spotify.Limit(50))
+	if err != nil {
+		return fmt.Errorf("failed to fetch saved albums: %w", err)
+	}
+
+	albums := page.Albums
+
+	// Pagination
+	for {
+		err := client.NextPage(ctx, page)
+		if err != nil {
+			if err.Error() == spotify.ErrNoMorePages.Error() {
+				break
+			}
+			return fmt.Errorf("error paginating saved albums: %w", err)
+		}
+		albums = append(albums, page.Albums...)
+	}
+	fmt.Fprintf(opts.out(), "Found %d saved albums.\n", len(albums))
+
+	var finalErr error
+	for _, saved := range albums {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		album := saved.FullAlbum
+
+		tracks, err := fetchFullTracks(ctx, client, &album, "")
+		if err != nil {
+			log.Printf("Error fetching tracks of album %s: %v", album.Name, err)
+			finalErr = err
+			continue
+		}
+
+		fmt.Fprintf(opts.out(), "=> %s\n", album.Name)
+		albumOpts := opts
+		albumOpts.OutputDir = albumOutputDir(opts, album.Name)
+
+		if err := DownloadTrackList(ctx, client, tracks, nil, albumOpts); err != nil {
+			finalErr = err
+		}
+	}
+
+	return finalErr
+}
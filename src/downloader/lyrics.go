@@ -0,0 +1,49 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"playlist-download/src/lyrics"
+	"strings"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+// fetchLyrics returns the lyrics of the track, or nil when lyrics are disabled or not found.
+// Missing lyrics never fail a track.
+func fetchLyrics(ctx context.Context, track spotify.FullTrack, opts Options) *lyrics.Lyrics {
+	if opts.Lyrics == nil {
+		return nil
+	}
+
+	trackLyrics, err := opts.Lyrics.Get(ctx, track)
+	if err != nil {
+		if errors.Is(err, lyrics.ErrNotFound) {
+			log.Printf("No lyrics found for '%s'\n", track.Name)
+		} else {
+			log.Printf("Error fetching lyrics for '%s': %v\n", track.Name, err)
+		}
+		return nil
+	}
+	return trackLyrics
+}
+
+// writeLyricsFile writes the synced lyrics to a .lrc file next to fileName when
+// opts.LyricsSidecar is set. It is called once the track is tagged, so a track that
+// failed leaves no lyrics file behind.
+func writeLyricsFile(track spotify.FullTrack, fileName string, trackLyrics *lyrics.Lyrics, opts Options) {
+	if !opts.LyricsSidecar || trackLyrics == nil || strings.TrimSpace(trackLyrics.Synced) == "" {
+		return
+	}
+	if err := os.WriteFile(lrcPath(fileName), []byte(trackLyrics.Synced+"\n"), 0644); err != nil {
+		log.Printf("Error writing lyrics file for '%s': %v\n", track.Name, err)
+	}
+}
+
+// lrcPath returns the sidecar lyrics file of an audio file: same name, .lrc extension.
+func lrcPath(fileName string) string {
+	return strings.TrimSuffix(fileName, filepath.Ext(fileName)) + ".lrc"
+}
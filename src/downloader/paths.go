@@ -0,0 +1,116 @@
+package downloader
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"playlist-download/src/naming"
+	"playlist-download/src/state"
+	"strings"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+// CollisionRule decides what happens when two different tracks render to the same file.
+type CollisionRule string
+
+const (
+	// CollisionNumber appends " (2)", " (3)", ... to the name of the later track
+	CollisionNumber CollisionRule = ""
+	// CollisionSkip downloads only the first track
+	CollisionSkip CollisionRule = "skip"
+	// CollisionOverwrite lets the later track replace the file, as older versions did
+	CollisionOverwrite CollisionRule = "overwrite"
+)
+
+func ParseCollisionRule(input string) (CollisionRule, error) {
+	input = strings.ToLower(input)
+	switch input {
+	case "", "number":
+		return CollisionNumber, nil
+	case "skip":
+		return CollisionSkip, nil
+	case "overwrite":
+		return CollisionOverwrite, nil
+	default:
+		return CollisionNumber, fmt.Errorf("invalid collision rule: '%s' (valid: number, skip, overwrite)", input)
+	}
+}
+
+// template returns the naming template of the run, the flat "{title}" one by default.
+func (o Options) template() *naming.Template {
+	if o.Template != nil {
+		return o.Template
+	}
+	t, _ := naming.Parse(naming.DefaultTemplate)
+	return t
+}
+
+// albumOutputDir is where a release downloaded with others (artist discography, saved
+// albums) goes: its own folder, unless the template already builds a folder layout.
+func albumOutputDir(opts Options, albumName string) string {
+	if opts.template().HasFolders() {
+		return opts.OutputDir
+	}
+	return filepath.Join(opts.OutputDir, sanitizeFileName(albumName))
+}
+
+// renderTrackPath returns the path the template gives to track, before collisions are resolved.
+func renderTrackPath(track spotify.FullTrack, index int, opts Options) string {
+	return filepath.Join(opts.OutputDir, opts.template().Render(track, index)+"."+opts.Format.Extension())
+}
+
+// resolveTrackPaths picks the file of every track, in list order, so the same list always
+// gives the same names. Tracks downloaded by a previous run keep their file and claim it
+// first; an empty path means the track lost a collision under CollisionSkip. Under
+// CollisionOverwrite, shared holds the paths (lowercase) given to more than one track.
+func resolveTrackPaths(tracks []spotify.FullTrack, store *state.Store, opts Options) (paths []string, shared map[string]bool) {
+	// Keys are lowercase: "Intro" and "intro" are the same file on Windows and macOS
+	claims := make(map[string]string)
+	for _, e := range store.All() {
+		if e.Path != "" && e.Status == state.StatusDone {
+			claims[strings.ToLower(e.Path)] = e.TrackID
+		}
+	}
+
+	paths = make([]string, len(tracks))
+	shared = make(map[string]bool)
+	for i, track := range tracks {
+		trackID := trackKey(track)
+		if e, ok := store.Get(trackID); ok && e.Path != "" && e.Status == state.StatusDone {
+			paths[i] = e.Path
+			continue
+		}
+
+		path := renderTrackPath(track, i, opts)
+		owner, claimed := claims[strings.ToLower(path)]
+		if claimed && owner != trackID {
+			switch opts.Collision {
+			case CollisionSkip:
+				log.Printf("File name collision: '%s' would overwrite %s\n", track.Name, path)
+				continue
+			case CollisionOverwrite:
+				log.Printf("File name collision: '%s' overwrites %s\n", track.Name, path)
+				shared[strings.ToLower(path)] = true
+			default:
+				path = numberedPath(path, claims)
+			}
+		}
+
+		claims[strings.ToLower(path)] = trackID
+		paths[i] = path
+	}
+	return paths, shared
+}
+
+// numberedPath returns the first "name (N).ext" that no track has claimed yet.
+func numberedPath(path string, claims map[string]string) string {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	for n := 2; ; n++ {
+		candidate := fmt.Sprintf("%s (%d)%s", base, n, ext)
+		if _, taken := claims[strings.ToLower(candidate)]; !taken {
+			return candidate
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package downloader
+
+import (
+	"path/filepath"
+	"playlist-download/src/state"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+func namedTrack(id string, name string) spotify.FullTrack {
+	track := testTrack(id)
+	track.Name = name
+	return track
+}
+
+func TestResolveTrackPaths(t *testing.T) {
+	tracks := []spotify.FullTrack{
+		namedTrack("a", "Intro"),
+		namedTrack("b", "intro"),
+		namedTrack("c", "Intro"),
+		// "Other.mp3" belongs to a track downloaded by a previous run
+		namedTrack("d", "Other"),
+		// Downloaded before under another name: it keeps its file
+		namedTrack("kept", "Renamed"),
+		// The same track twice gets the same file, never a collision
+		namedTrack("a", "Intro"),
+	}
+
+	tests := []struct {
+		collision  CollisionRule
+		want       []string
+		wantShared []string
+	}{
+		{
+			collision: CollisionNumber,
+			want:      []string{"Intro.mp3", "intro (2).mp3", "Intro (3).mp3", "Other (2).mp3", "Kept.mp3", "Intro.mp3"},
+		},
+		{
+			collision: CollisionSkip,
+			want:      []string{"Intro.mp3", "", "", "", "Kept.mp3", "Intro.mp3"},
+		},
+		{
+			collision:  CollisionOverwrite,
+			want:       []string{"Intro.mp3", "intro.mp3", "Intro.mp3", "Other.mp3", "Kept.mp3", "Intro.mp3"},
+			wantShared: []string{"intro.mp3", "other.mp3"},
+		},
+	}
+
+	for _, tt := range tests {
+		name := string(tt.collision)
+		if name == "" {
+			name = "number"
+		}
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			store, err := state.Load(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for id, file := range map[string]string{"old": "Other.mp3", "kept": "Kept.mp3"} {
+				if err := store.Set(state.Entry{TrackID: id, Path: filepath.Join(dir, file), Status: state.StatusDone}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			paths, shared := resolveTrackPaths(tracks, store, Options{OutputDir: dir, Collision: tt.collision})
+
+			var got []string
+			for _, p := range paths {
+				if p != "" {
+					p = filepath.Base(p)
+				}
+				got = append(got, p)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("paths = %q, want %q", got, tt.want)
+			}
+
+			wantShared := make(map[string]bool)
+			for _, file := range tt.wantShared {
+				wantShared[strings.ToLower(filepath.Join(dir, file))] = true
+			}
+			if !reflect.DeepEqual(shared, wantShared) {
+				t.Errorf("shared = %v, want %v", shared, wantShared)
+			}
+		})
+	}
+}
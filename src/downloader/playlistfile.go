@@ -0,0 +1,59 @@
+package downloader
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"playlist-download/src/playlistfile"
+	"playlist-download/src/state"
+	"strings"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+// writePlaylistFiles writes the playlist files of opts.PlaylistFormats in opts.OutputDir,
+// named after the playlist. Tracks keep the Spotify order; the ones without a downloaded
+// file (failed, skipped) are left out.
+func writePlaylistFiles(name string, tracks []spotify.FullTrack, opts Options) error {
+	if opts.DryRun || len(opts.PlaylistFormats) == 0 {
+		return nil
+	}
+
+	store, err := state.Load(opts.OutputDir)
+	if err != nil {
+		return err
+	}
+
+	var entries []playlistfile.Entry
+	for _, track := range tracks {
+		e, ok := store.Get(trackKey(track))
+		if !ok || e.Status != state.StatusDone || e.Path == "" {
+			continue
+		}
+		if _, err := os.Stat(e.Path); err != nil {
+			continue
+		}
+		relPath, err := filepath.Rel(opts.OutputDir, e.Path)
+		if err != nil {
+			continue
+		}
+
+		var artists []string
+		for _, a := range track.Artists {
+			artists = append(artists, a.Name)
+		}
+		entries = append(entries, playlistfile.Entry{
+			Path:     relPath,
+			Title:    track.Name,
+			Artist:   strings.Join(artists, ", "),
+			Album:    track.Album.Name,
+			Duration: int(track.Duration) / 1000,
+		})
+	}
+
+	written, err := playlistfile.Write(opts.OutputDir, sanitizeFileName(name), entries, opts.PlaylistFormats)
+	for _, path := range written {
+		fmt.Fprintf(opts.out(), "Playlist file written to %s (%d tracks).\n", path, len(entries))
+	}
+	return err
+}
@@ -0,0 +1,278 @@
+package downloader
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"playlist-download/src/utils"
+	yt "playlist-download/src/yt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+// FailedFileName is the file, in the output directory, listing the tracks that failed.
+const FailedFileName = "failed.json"
+
+type ResultStatus string
+
+const (
+	ResultDone    ResultStatus = "done"
+	ResultSkipped ResultStatus = "skipped"
+	ResultFailed  ResultStatus = "failed"
+	// ResultInterrupted tracks were stopped or never started because of a Ctrl-C
+	ResultInterrupted ResultStatus = "interrupted"
+)
+
+// FailureCategory tells why a track failed.
+type FailureCategory string
+
+const (
+	FailureNoMatch       FailureCategory = "no-match"
+	FailureQuota         FailureCategory = "quota"
+	FailureSearch        FailureCategory = "search"
+	FailureAgeRestricted FailureCategory = "age-restricted"
+	FailureYtDlp         FailureCategory = "yt-dlp"
+	FailureTag           FailureCategory = "tag"
+	FailureOther         FailureCategory = "other"
+)
+
+// TrackResult is the outcome of a single track of DownloadTrackList.
+type TrackResult struct {
+	Index    int
+	Track    spotify.FullTrack
+	Status   ResultStatus
+	Category FailureCategory
+	Err      error
+	VideoID  string
+	// Attempts is how many times yt-dlp was run, 0 if the track never got that far
+	Attempts int
+	Duration time.Duration
+	// Path is the file of a downloaded track
+	Path string
+	// Reason explains why a track was skipped
+	Reason string
+}
+
+// FailedTrack is an entry of failed.json. The whole Spotify track is kept, so retry-failed
+// needs neither the original URL nor Spotify credentials.
+type FailedTrack struct {
+	TrackID  string            `json:"track_id"`
+	Artist   string            `json:"artist"`
+	Title    string            `json:"title"`
+	Category FailureCategory   `json:"category"`
+	Error    string            `json:"error"`
+	VideoID  string            `json:"video_id,omitempty"`
+	Attempts int               `json:"attempts"`
+	Seconds  float64           `json:"seconds"`
+	FailedAt time.Time         `json:"failed_at"`
+	Track    spotify.FullTrack `json:"track"`
+}
+
+// TracksFailedError is returned by DownloadTrackList when some tracks failed.
+type TracksFailedError struct {
+	Failed []TrackResult
+	Total  int
+}
+
+func (e *TracksFailedError) Error() string {
+	if len(e.Failed) == 1 {
+		return fmt.Sprintf("1 of %d tracks failed: %v", e.Total, e.Failed[0].Err)
+	}
+	return fmt.Sprintf("%d of %d tracks failed", len(e.Failed), e.Total)
+}
+
+// Has reports whether any track failed for the given reason.
+func (e *TracksFailedError) Has(category FailureCategory) bool {
+	for _, r := range e.Failed {
+		if r.Category == category {
+			return true
+		}
+	}
+	return false
+}
+
+// classifySearchError returns the category of an error from the YouTube search.
+func classifySearchError(err error) FailureCategory {
+	switch {
+	case yt.IsQuotaExceeded(err):
+		return FailureQuota
+	case errors.Is(err, yt.ErrNoResults):
+		return FailureNoMatch
+	default:
+		return FailureSearch
+	}
+}
+
+// ageRestrictedMessages are printed by yt-dlp for videos that need a logged in account.
+var ageRestrictedMessages = []string{
+	"confirm your age",
+	"age-restricted",
+	"inappropriate for some users",
+}
+
+// classifyDownloadError returns the category of an error from yt-dlp.
+func classifyDownloadError(err error) FailureCategory {
+	msg := strings.ToLower(err.Error())
+	for _, m := range ageRestrictedMessages {
+		if strings.Contains(msg, m) {
+			return FailureAgeRestricted
+		}
+	}
+	return FailureYtDlp
+}
+
+// trackKey identifies a track in the state file and in failed.json: its Spotify ID or, for
+// the tracks that have none (e.g. imported from a CSV file), its ISRC or artist and title.
+func trackKey(track spotify.FullTrack) string {
+	if track.ID != "" {
+		return string(track.ID)
+	}
+	if isrc := utils.TrackISRC(track); isrc != "" {
+		return "isrc:" + strings.ToUpper(isrc)
+	}
+	return strings.ToLower(trackLabel(track))
+}
+
+// LoadFailedTracks reads failed.json from outputDir. A missing file yields no tracks.
+func LoadFailedTracks(outputDir string) ([]FailedTrack, error) {
+	data, err := os.ReadFile(filepath.Join(outputDir, FailedFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", FailedFileName, err)
+	}
+
+	var failed []FailedTrack
+	if err := json.Unmarshal(data, &failed); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", FailedFileName, err)
+	}
+	return failed, nil
+}
+
+// updateFailedFile merges the results of a run into failed.json: the tracks processed by the
+// run leave the file unless they failed again, the others (e.g. failures of other albums
+// downloaded into the same folder) stay. The file is removed when nothing failed.
+func updateFailedFile(outputDir string, results []TrackResult) error {
+	existing, err := LoadFailedTracks(outputDir)
+	if err != nil {
+		return err
+	}
+
+	processed := make(map[string]bool)
+	var failed []FailedTrack
+	for _, r := range results {
+		if r.Status == ResultInterrupted {
+			continue
+		}
+		processed[trackKey(r.Track)] = true
+		if r.Status == ResultFailed {
+			failed = append(failed, newFailedTrack(r))
+		}
+	}
+
+	var merged []FailedTrack
+	for _, f := range existing {
+		if !processed[trackKey(f.Track)] {
+			merged = append(merged, f)
+		}
+	}
+	merged = append(merged, failed...)
+
+	path := filepath.Join(outputDir, FailedFileName)
+	if len(merged) == 0 {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove %s: %w", FailedFileName, err)
+		}
+		return nil
+	}
+
+	data, err := json.MarshalIndent(merged, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", FailedFileName, err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", FailedFileName, err)
+	}
+	return nil
+}
+
+func newFailedTrack(r TrackResult) FailedTrack {
+	artist := ""
+	if len(r.Track.Artists) > 0 {
+		artist = r.Track.Artists[0].Name
+	}
+	errMsg := ""
+	if r.Err != nil {
+		errMsg = r.Err.Error()
+	}
+	return FailedTrack{
+		TrackID:  string(r.Track.ID),
+		Artist:   artist,
+		Title:    r.Track.Name,
+		Category: r.Category,
+		Error:    errMsg,
+		VideoID:  r.VideoID,
+		Attempts: r.Attempts,
+		Seconds:  r.Duration.Round(time.Millisecond).Seconds(),
+		FailedAt: time.Now(),
+		Track:    r.Track,
+	}
+}
+
+// printSummary prints how many tracks ended in each status and a table of the failed ones.
+func printSummary(w io.Writer, results []TrackResult) {
+	counts := make(map[ResultStatus]int)
+	var failed []TrackResult
+	for _, r := range results {
+		counts[r.Status]++
+		if r.Status == ResultFailed {
+			failed = append(failed, r)
+		}
+	}
+
+	summary := fmt.Sprintf("Summary: %d done, %d skipped, %d failed", counts[ResultDone], counts[ResultSkipped], counts[ResultFailed])
+	if counts[ResultInterrupted] > 0 {
+		summary += fmt.Sprintf(", %d not processed", counts[ResultInterrupted])
+	}
+	fmt.Fprintln(w, summary+".")
+	if len(failed) == 0 {
+		return
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "#\tTRACK\tCATEGORY\tATTEMPTS\tTIME\tERROR")
+	for _, r := range failed {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%d\t%s\t%s\n",
+			r.Index+1,
+			truncateText(trackLabel(r.Track), 40),
+			r.Category,
+			r.Attempts,
+			r.Duration.Round(time.Second),
+			truncateText(firstLine(r.Err), 60),
+		)
+	}
+	tw.Flush()
+}
+
+func firstLine(err error) string {
+	if err == nil {
+		return ""
+	}
+	line, _, _ := strings.Cut(strings.TrimSpace(err.Error()), "\n")
+	return line
+}
+
+func truncateText(s string, max int) string {
+	r := []rune(s)
+	if len(r) <= max {
+		return s
+	}
+	return string(r[:max-1]) + "…"
+}
@@ -0,0 +1,76 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"path/filepath"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+// RetryFailed downloads again the tracks listed in the failed.json files of opts.OutputDir
+// and of its subfolders, where artist and library downloads keep one per album. The tracks
+// are read from the files, so no Spotify client is needed.
+func RetryFailed(ctx context.Context, opts Options) error {
+	dirs, err := findFailedFiles(opts.OutputDir)
+	if err != nil {
+		return err
+	}
+	if len(dirs) == 0 {
+		fmt.Fprintf(opts.out(), "No %s found in %s: nothing to retry.\n", FailedFileName, opts.OutputDir)
+		return nil
+	}
+
+	var finalErr error
+	for _, dir := range dirs {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		failed, err := LoadFailedTracks(dir)
+		if err != nil {
+			log.Printf("Error reading failed tracks of %s: %v", dir, err)
+			finalErr = err
+			continue
+		}
+		var tracks []spotify.FullTrack
+		for _, f := range failed {
+			tracks = append(tracks, f.Track)
+		}
+		if len(tracks) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(opts.out(), "=> Retrying %d failed tracks in %s\n", len(tracks), dir)
+		dirOpts := opts
+		dirOpts.OutputDir = dir
+		if err := DownloadTrackList(ctx, nil, tracks, nil, dirOpts); err != nil {
+			if errors.Is(err, context.Canceled) {
+				return err
+			}
+			finalErr = err
+		}
+	}
+	return finalErr
+}
+
+// findFailedFiles returns the folders, under root, holding a failed.json.
+func findFailedFiles(root string) ([]string, error) {
+	var dirs []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && d.Name() == FailedFileName {
+			dirs = append(dirs, filepath.Dir(path))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to look for %s: %w", FailedFileName, err)
+	}
+	return dirs, nil
+}
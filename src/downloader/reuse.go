@@ -0,0 +1,184 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"playlist-download/src/report"
+	"playlist-download/src/state"
+	"playlist-download/src/tags"
+	"time"
+)
+
+// reuseTrack gives track index of b the file downloaded for the same recording in another
+// batch (primary), instead of downloading it again. The same Spotify track gets a hard link
+// when possible; a different release of the recording (same ISRC) gets a copy tagged with
+// its own metadata.
+func reuseTrack(ctx context.Context, b *batch, index int, primary trackJob) TrackResult {
+	start := time.Now()
+	track := b.tracks[index]
+	job := trackJob{index: index, track: track, path: b.paths[index], batch: b}
+	source := primary.batch.results[primary.index]
+	result := TrackResult{Index: index, Track: track, Status: ResultSkipped, VideoID: source.VideoID}
+	defer func() {
+		result.Duration = time.Since(start)
+		if result.Status != ResultInterrupted {
+			emitTrackResult(b.opts.Events, job, result)
+		}
+	}()
+
+	// In dry-run every track gets its report entry, as in processSingleTrack
+	addEntry := func(note string, err error, sameMatch bool) {
+		if !b.opts.DryRun {
+			return
+		}
+		entry := newReportEntry(job)
+		entry.Note = note
+		if err != nil {
+			entry.Error = err.Error()
+		}
+		if sameMatch && source.VideoID != "" {
+			entry.Candidates = []report.Candidate{{
+				VideoID: source.VideoID,
+				URL:     "https://www.youtube.com/watch?v=" + source.VideoID,
+				Picked:  true,
+				Reason:  "picked: match of the same recording in " + primary.batch.name,
+			}}
+		}
+		b.opts.Report.Add(entry)
+	}
+	sameAs := fmt.Sprintf("same recording as #%d '%s' in %s", primary.index+1, trackLabel(primary.track), primary.batch.name)
+
+	switch {
+	case ctx.Err() != nil || source.Status == ResultInterrupted:
+		result.Status = ResultInterrupted
+		result.Err = ctx.Err()
+		return result
+	case source.Status == ResultFailed:
+		if !b.opts.DryRun {
+			markFailed(b.store, track, source.VideoID, source.Err)
+		}
+		addEntry(sameAs+", which failed", source.Err, true)
+		result.Status = ResultFailed
+		result.Category = source.Category
+		result.Err = source.Err
+		return result
+	case job.path == "":
+		addEntry("skipped: file name collision", nil, false)
+		result.Reason = "file name collision"
+		return result
+	case b.store.IsDone(trackKey(track)) && fileExists(job.path):
+		addEntry("already downloaded", nil, false)
+		result.Reason = "already downloaded"
+		result.Path = job.path
+		return result
+	case source.Status == ResultSkipped && source.Path == "":
+		// Skipped in the other batch, e.g. by an override
+		result.Reason = "skipped in " + primary.batch.name + ": " + source.Reason
+		addEntry(sameAs+", skipped: "+source.Reason, nil, false)
+		return result
+	case b.opts.DryRun:
+		addEntry(sameAs+": would reuse its file", nil, true)
+		result.Status = ResultDone
+		return result
+	}
+
+	if result.VideoID == "" {
+		if e, ok := primary.batch.store.Get(trackKey(primary.track)); ok {
+			result.VideoID = e.VideoID
+		}
+	}
+
+	// Tracks without a Spotify ID (CSV, text lists) are only known to be the same recording
+	sameTrack := track.ID != "" && primary.track.ID == track.ID
+	if err := reuseFile(source.Path, job.path, sameTrack); err != nil {
+		log.Printf("Error copying '%s' from %s: %v\n", track.Name, primary.batch.name, err)
+		markFailed(b.store, track, result.VideoID, err)
+		result.Status = ResultFailed
+		result.Category = FailureOther
+		result.Err = err
+		return result
+	}
+	if !sameTrack {
+		coverArt := b.coverArt
+		if coverArt == nil {
+			coverArt = b.opts.Covers.Get(track.Album)
+		}
+		trackLyrics := fetchLyrics(ctx, track, b.opts)
+		if err := tags.TagFile(ctx, job.path, track, coverArt, "https://www.youtube.com/watch?v="+result.VideoID, trackLyrics); err != nil {
+			// Stopped by Ctrl-C: the copy still has the tags of the other track
+			if ctx.Err() != nil {
+				removePartialFiles(job.path)
+				result.Status = ResultInterrupted
+				result.Err = ctx.Err()
+				return result
+			}
+			log.Printf("Error tagging '%s': %v\n", track.Name, err)
+			markFailed(b.store, track, result.VideoID, err)
+			result.Status = ResultFailed
+			result.Category = FailureTag
+			result.Err = err
+			return result
+		}
+		writeLyricsFile(track, job.path, trackLyrics, b.opts)
+	}
+
+	if err := b.store.Set(state.Entry{
+		TrackID: trackKey(track),
+		VideoID: result.VideoID,
+		Path:    job.path,
+		Status:  state.StatusDone,
+	}); err != nil {
+		log.Printf("Error saving state for '%s': %v\n", track.Name, err)
+	}
+	log.Printf("Reused '%s' from %s\n", track.Name, primary.batch.name)
+	result.Status = ResultDone
+	result.Path = job.path
+	return result
+}
+
+// reuseFile puts the file src at dst: as a hard link if link is true and the file system
+// allows it, as a copy otherwise.
+func reuseFile(src string, dst string, link bool) error {
+	// Batches sharing a folder can resolve to the very same file: removing dst would lose src
+	if filepath.Clean(src) == filepath.Clean(dst) {
+		return nil
+	}
+	if srcInfo, err := os.Stat(src); err == nil {
+		if dstInfo, err := os.Stat(dst); err == nil && os.SameFile(srcInfo, dstInfo) {
+			return nil
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return fmt.Errorf("error creating folder: %w", err)
+	}
+	if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if link && os.Link(src, dst) == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
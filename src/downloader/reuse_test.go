@@ -0,0 +1,184 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"playlist-download/src/report"
+	"playlist-download/src/state"
+	"strings"
+	"testing"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+func isrcTrack(id string, isrc string) spotify.FullTrack {
+	track := testTrack(id)
+	track.ExternalIDs = map[string]string{"isrc": isrc}
+	return track
+}
+
+// testBatch returns a batch writing tracks into dir, as downloadBatches prepares it.
+func testBatch(t *testing.T, name string, dir string, tracks []spotify.FullTrack, opts Options) *batch {
+	t.Helper()
+	store, err := state.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts.OutputDir = dir
+	b := newBatch(name, tracks, nil, opts)
+	b.store = store
+	b.results = make([]TrackResult, len(tracks))
+	for _, track := range tracks {
+		b.paths = append(b.paths, filepath.Join(dir, track.Name+".mp3"))
+	}
+	return b
+}
+
+func TestPlanReuse(t *testing.T) {
+	dir := t.TempDir()
+	first := testBatch(t, "first", filepath.Join(dir, "first"), []spotify.FullTrack{
+		isrcTrack("a", "USAAA0000001"),
+		isrcTrack("b", "USAAA0000002"),
+		// Same recording twice in one batch: both are downloaded, as without other batches
+		isrcTrack("b2", "usaaa0000002"),
+	}, Options{})
+	second := testBatch(t, "second", filepath.Join(dir, "second"), []spotify.FullTrack{
+		isrcTrack("b3", "USAAA0000002"),
+		isrcTrack("c", "USAAA0000003"),
+	}, Options{})
+
+	queued := planReuse([]*batch{first, second})
+
+	var got []string
+	for _, job := range queued {
+		got = append(got, job.batch.name+"/"+string(job.track.ID))
+	}
+	if want := "first/a first/b first/b2 second/c"; strings.Join(got, " ") != want {
+		t.Errorf("queued %v, want %s", got, want)
+	}
+	if len(first.reuse) != 0 {
+		t.Errorf("first batch reuses %v, want nothing", first.reuse)
+	}
+	primary, ok := second.reuse[0]
+	if !ok || primary.batch != first || primary.index != 1 || len(second.reuse) != 1 {
+		t.Errorf("second batch reuses %v, want its track 0 from track 1 of the first batch", second.reuse)
+	}
+
+	// A single batch never reuses, even its own duplicates
+	only := testBatch(t, "", dir, []spotify.FullTrack{isrcTrack("a", "USAAA0000001"), isrcTrack("a2", "USAAA0000001")}, Options{})
+	if queued := planReuse([]*batch{only}); len(queued) != 2 || len(only.reuse) != 0 {
+		t.Errorf("single batch: queued %d tracks and reuses %v, want 2 and nothing", len(queued), only.reuse)
+	}
+}
+
+func TestReuseTrack(t *testing.T) {
+	tests := []struct {
+		name string
+		// samePath puts both batches in the same folder, so the two tracks share their file
+		samePath   bool
+		source     TrackResult
+		dryRun     bool
+		wantStatus ResultStatus
+		wantFile   bool
+		wantState  state.Status
+		wantNote   string
+	}{
+		{
+			name:       "downloaded",
+			source:     TrackResult{Status: ResultDone, VideoID: "vid"},
+			wantStatus: ResultDone,
+			wantFile:   true,
+			wantState:  state.StatusDone,
+		},
+		{
+			name:       "same file",
+			samePath:   true,
+			source:     TrackResult{Status: ResultDone, VideoID: "vid"},
+			wantStatus: ResultDone,
+			wantFile:   true,
+			wantState:  state.StatusDone,
+		},
+		{
+			name:       "failed",
+			source:     TrackResult{Status: ResultFailed, VideoID: "vid", Category: FailureYtDlp, Err: errors.New("download failed")},
+			wantStatus: ResultFailed,
+			wantState:  state.StatusFailed,
+		},
+		{
+			name:       "skipped",
+			source:     TrackResult{Status: ResultSkipped, Reason: "skipped by override"},
+			wantStatus: ResultSkipped,
+		},
+		{
+			name:       "dry-run",
+			source:     TrackResult{Status: ResultDone, VideoID: "vid"},
+			dryRun:     true,
+			wantStatus: ResultDone,
+			wantNote:   "would reuse its file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			opts := Options{DryRun: tt.dryRun, Report: report.New()}
+			// The same Spotify track in two playlists: reused as a hard link, without tagging
+			track := isrcTrack("a", "USAAA0000001")
+			primaryBatch := testBatch(t, "first", filepath.Join(dir, "first"), []spotify.FullTrack{track}, opts)
+			otherDir := filepath.Join(dir, "second")
+			if tt.samePath {
+				otherDir = primaryBatch.opts.OutputDir
+			}
+			b := testBatch(t, "second", otherDir, []spotify.FullTrack{track}, opts)
+
+			src := primaryBatch.paths[0]
+			source := tt.source
+			if source.Status == ResultDone {
+				writeTestFile(t, src)
+				source.Path = src
+			}
+			primaryBatch.results[0] = source
+
+			result := reuseTrack(context.Background(), b, 0, trackJob{index: 0, track: track, path: src, batch: primaryBatch})
+			if result.Status != tt.wantStatus {
+				t.Fatalf("status = %v (%v), want %v", result.Status, result.Err, tt.wantStatus)
+			}
+
+			dst := b.paths[0]
+			if got := fileExists(dst); got != tt.wantFile {
+				t.Errorf("reused file exists = %v, want %v", got, tt.wantFile)
+			}
+			if tt.wantFile {
+				if data, err := os.ReadFile(dst); err != nil || string(data) != filepath.Base(src) {
+					t.Errorf("reused file holds %q (%v), want the downloaded one", data, err)
+				}
+				if result.Path != dst || result.VideoID != "vid" {
+					t.Errorf("result path %q, video %q, want %q and vid", result.Path, result.VideoID, dst)
+				}
+			}
+
+			e, ok := b.store.Get(trackKey(track))
+			if tt.wantState == "" {
+				if ok {
+					t.Errorf("state entry %+v written, want none", e)
+				}
+			} else if !ok || e.Status != tt.wantState {
+				t.Errorf("state entry = %+v, want status %s", e, tt.wantState)
+			}
+
+			entries := opts.Report.Entries()
+			if tt.wantNote == "" {
+				if len(entries) != 0 {
+					t.Errorf("report entries %+v outside dry-run", entries)
+				}
+				return
+			}
+			if len(entries) != 1 || !strings.Contains(entries[0].Note, tt.wantNote) ||
+				len(entries[0].Candidates) != 1 || entries[0].Candidates[0].VideoID != "vid" {
+				t.Errorf("report entries = %+v, want one noting %q with the reused match", entries, tt.wantNote)
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"playlist-download/src/parser"
+	"strings"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+// ArtistOptions pick what an artist URL downloads.
+type ArtistOptions struct {
+	AlbumTypes []spotify.AlbumType
+	TopTracks  bool
+	Market     string
+}
+
+// DownloadSources downloads several Spotify URLs with a single pool of workers. Every album,
+// playlist and artist gets its own folder inside opts.OutputDir, named after it; single
+// tracks go straight into opts.OutputDir. A track found in more than one source is
+// downloaded once and copied into the other folders. A URL that can't be fetched is
+// reported and the others are downloaded anyway.
+func DownloadSources(ctx context.Context, client *spotify.Client, urls []string, artist ArtistOptions, opts Options) error {
+	var batches []*batch
+	// playlists get their playlist files once the downloads are over
+	var playlists []*batch
+	var tracksBatch *batch
+	folders := make(map[string]bool)
+	var sourceErr error
+	resolved := 0
+
+	for _, sourceURL := range urls {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		urlType, spotifyID, err := parser.ParseSpotifyURL(sourceURL)
+		if err != nil {
+			log.Printf("Skipping %s: %v", sourceURL, err)
+			sourceErr = errors.Join(sourceErr, fmt.Errorf("error parsing URL %s: %w", sourceURL, err))
+			continue
+		}
+
+		var sourceBatches []*batch
+		switch urlType {
+		case parser.AlbumURL:
+			sourceBatches, err = albumSource(ctx, client, spotifyID, opts, folders)
+		case parser.PlaylistURL:
+			var b *batch
+			if b, err = playlistSource(ctx, client, spotifyID, opts, folders); err == nil {
+				sourceBatches = []*batch{b}
+				playlists = append(playlists, b)
+			}
+		case parser.ArtistURL:
+			sourceBatches, err = artistSource(ctx, client, spotifyID, artist, opts, folders)
+		case parser.TrackURL:
+			var song *spotify.FullTrack
+			if song, err = client.GetTrack(ctx, spotify.ID(spotifyID)); err != nil {
+				err = fmt.Errorf("failed to fetch track: %w", err)
+				break
+			}
+			if tracksBatch == nil {
+				tracksBatch = newBatch("tracks", nil, nil, opts)
+				sourceBatches = []*batch{tracksBatch}
+			}
+			tracksBatch.tracks = append(tracksBatch.tracks, *song)
+		default:
+			err = errors.New("only album, playlist, track or artist URLs are supported")
+		}
+		if err != nil {
+			log.Printf("Skipping %s: %v", sourceURL, err)
+			sourceErr = errors.Join(sourceErr, fmt.Errorf("%s: %w", sourceURL, err))
+			continue
+		}
+		resolved++
+		batches = append(batches, sourceBatches...)
+	}
+
+	if len(batches) == 0 {
+		if sourceErr == nil {
+			return errors.New("no URL to download")
+		}
+		return sourceErr
+	}
+
+	fmt.Fprintf(opts.out(), "Downloading %d sources into %s.\n", resolved, opts.OutputDir)
+	downloadErr := downloadBatches(ctx, batches, opts)
+	if ctx.Err() != nil {
+		return downloadErr
+	}
+
+	for _, b := range playlists {
+		if err := writePlaylistFiles(b.name, b.tracks, b.opts); err != nil {
+			log.Printf("Error writing playlist files: %v", err)
+		}
+	}
+	return errors.Join(downloadErr, sourceErr)
+}
+
+// TrackList is a list of tracks that doesn't come from the Spotify API, e.g. read from a CSV file.
+type TrackList struct {
+	Name   string
+	Tracks []spotify.FullTrack
+}
+
+// DownloadTrackLists downloads lists without Spotify credentials and writes a playlist file
+// for each one, named after it. A single list goes into opts.OutputDir; with several, each
+// one gets its own folder, as in DownloadSources.
+func DownloadTrackLists(ctx context.Context, lists []TrackList, opts Options) error {
+	if len(lists) == 1 {
+		downloadErr := DownloadTrackList(ctx, nil, lists[0].Tracks, nil, opts)
+		// Also with some failed tracks: the playlist file lists the ones that made it
+		if err := writePlaylistFiles(lists[0].Name, lists[0].Tracks, opts); err != nil {
+			log.Printf("Error writing playlist files: %v", err)
+		}
+		return downloadErr
+	}
+
+	batches := make([]*batch, 0, len(lists))
+	folders := make(map[string]bool)
+	for _, list := range lists {
+		fmt.Fprintf(opts.out(), "=> %s: %d tracks\n", list.Name, len(list.Tracks))
+		batches = append(batches, newBatch(list.Name, list.Tracks, nil, sourceOptions(opts, list.Name, folders)))
+	}
+
+	fmt.Fprintf(opts.out(), "Downloading %d lists into %s.\n", len(lists), opts.OutputDir)
+	downloadErr := downloadBatches(ctx, batches, opts)
+	if ctx.Err() != nil {
+		return downloadErr
+	}
+	for _, b := range batches {
+		if err := writePlaylistFiles(b.name, b.tracks, b.opts); err != nil {
+			log.Printf("Error writing playlist files: %v", err)
+		}
+	}
+	return downloadErr
+}
+
+// sourceOptions returns opts with OutputDir set to the folder of a source called name.
+// Two sources with the same name (e.g. two playlists called "Favorites") get different folders.
+func sourceOptions(opts Options, name string, folders map[string]bool) Options {
+	folder := sanitizeFileName(strings.TrimSpace(name))
+	if folder == "" {
+		folder = "Unknown"
+	}
+	candidate := folder
+	for n := 2; folders[strings.ToLower(candidate)]; n++ {
+		candidate = fmt.Sprintf("%s (%d)", folder, n)
+	}
+	folders[strings.ToLower(candidate)] = true
+
+	sourceOpts := opts
+	sourceOpts.OutputDir = filepath.Join(opts.OutputDir, candidate)
+	return sourceOpts
+}
+
+func albumSource(ctx context.Context, client *spotify.Client, albumID string, opts Options, folders map[string]bool) ([]*batch, error) {
+	album, err := client.GetAlbum(ctx, spotify.ID(albumID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch album: %w", err)
+	}
+	if album == nil {
+		return nil, fmt.Errorf("album %s not found (empty response)", albumID)
+	}
+	tracks, err := fetchFullTracks(ctx, client, album, "")
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(opts.out(), "=> Album %s: %d tracks\n", album.Name, len(tracks))
+	return []*batch{newBatch(album.Name, tracks, nil, sourceOptions(opts, album.Name, folders))}, nil
+}
+
+func playlistSource(ctx context.Context, client *spotify.Client, playlistID string, opts Options, folders map[string]bool) (*batch, error) {
+	tracks, err := fetchPlaylistTracks(ctx, client, playlistID)
+	if err != nil {
+		return nil, err
+	}
+	name, coverArt := fetchPlaylistDetails(ctx, client, playlistID, opts.Cover == CoverPlaylist)
+	fmt.Fprintf(opts.out(), "=> Playlist %s: %d tracks\n", name, len(tracks))
+	return newBatch(name, tracks, coverArt, sourceOptions(opts, name, folders)), nil
+}
+
+func artistSource(ctx context.Context, client *spotify.Client, artistID string, artist ArtistOptions, opts Options, folders map[string]bool) ([]*batch, error) {
+	fullArtist, err := client.GetArtist(ctx, spotify.ID(artistID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch artist: %w", err)
+	}
+	fmt.Fprintf(opts.out(), "=> Artist %s\n", fullArtist.Name)
+	artistOpts := sourceOptions(opts, fullArtist.Name, folders)
+
+	if artist.TopTracks {
+		tracks, err := client.GetArtistsTopTracks(ctx, spotify.ID(artistID), artist.Market)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch artist top tracks: %w", err)
+		}
+		return []*batch{newBatch(fullArtist.Name, tracks, nil, artistOpts)}, nil
+	}
+
+	batches, err := artistBatches(ctx, client, artistID, artist.AlbumTypes, artist.Market, artistOpts)
+	if len(batches) == 0 {
+		return nil, err
+	}
+	if err != nil {
+		log.Printf("Some releases of %s could not be fetched: %v", fullArtist.Name, err)
+	}
+	return batches, nil
+}
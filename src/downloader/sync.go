@@ -0,0 +1,202 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"playlist-download/src/state"
+	"strings"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+// TrashDirName is the folder, inside the output directory, where removed tracks are moved.
+const TrashDirName = ".trash"
+
+type RemovalMode string
+
+const (
+	RemovalKeep   RemovalMode = ""
+	RemovalTrash  RemovalMode = "trash"
+	RemovalDelete RemovalMode = "delete"
+)
+
+func ParseRemovalMode(input string) (RemovalMode, error) {
+	input = strings.ToLower(input)
+	switch input {
+	case "", "keep", "none":
+		return RemovalKeep, nil
+	case "trash":
+		return RemovalTrash, nil
+	case "delete":
+		return RemovalDelete, nil
+	default:
+		return RemovalKeep, fmt.Errorf("invalid removal mode: '%s' (valid: keep, trash, delete)", input)
+	}
+}
+
+// SyncPlaylist mirrors a Spotify playlist into opts.OutputDir. Tracks added to the playlist
+// since the last run are downloaded; tracks removed from it are kept, moved to the trash
+// folder or deleted depending on removal.
+func SyncPlaylist(ctx context.Context, client *spotify.Client, playlistID string, removal RemovalMode, opts Options) error {
+	trackList, err := fetchPlaylistTracks(ctx, client, playlistID)
+	if err != nil {
+		return err
+	}
+
+	store, err := state.Load(opts.OutputDir)
+	if err != nil {
+		return err
+	}
+
+	newTracks := 0
+	for _, track := range trackList {
+		if !store.IsDone(trackKey(track)) {
+			newTracks++
+		}
+	}
+	fmt.Fprintf(opts.out(), "Sync: %d tracks in playlist, %d to download.\n", len(trackList), newTracks)
+
+	name, coverArt := fetchPlaylistDetails(ctx, client, playlistID, opts.Cover == CoverPlaylist)
+
+	var downloadErr error
+	if newTracks > 0 {
+		downloadErr = DownloadTrackList(ctx, client, trackList, coverArt, opts)
+	}
+
+	// Nothing is removed after an interrupted download
+	if ctx.Err() != nil {
+		return downloadErr
+	}
+
+	// DownloadTrackList updated the state file on disk: reload it before pruning
+	store, err = state.Load(opts.OutputDir)
+	if err != nil {
+		return errors.Join(downloadErr, err)
+	}
+
+	pruneErr := pruneRemovedTracks(store, playlistID, trackList, opts.OutputDir, removal, opts.out())
+
+	// The order may have changed even when no track was added or removed
+	if err := writePlaylistFiles(name, trackList, opts); err != nil {
+		log.Printf("Error writing playlist files: %v", err)
+	}
+
+	return errors.Join(downloadErr, pruneErr)
+}
+
+// pruneRemovedTracks handles the files of the tracks that were in the playlist at the last
+// sync and are no longer. Only the tracks recorded for playlistID are considered: the output
+// directory may hold other downloads, and a track still listed by another synced playlist
+// keeps its file. It then records the tracks of the playlist for the next sync.
+func pruneRemovedTracks(store *state.Store, playlistID string, trackList []spotify.FullTrack, outputDir string, removal RemovalMode, out io.Writer) error {
+	current := make(map[string]bool, len(trackList))
+	var listed []string
+	for _, track := range trackList {
+		if key := trackKey(track); !current[key] {
+			current[key] = true
+			listed = append(listed, key)
+		}
+	}
+
+	// Tracks still listed by the other synced playlists of the folder
+	shared := make(map[string]bool)
+	for id, tracks := range store.Playlists {
+		if id == playlistID {
+			continue
+		}
+		for _, key := range tracks {
+			shared[key] = true
+		}
+	}
+
+	var removed []string
+	for _, key := range store.PlaylistTracks(playlistID) {
+		if !current[key] {
+			removed = append(removed, key)
+		}
+	}
+
+	var finalErr error
+	switch {
+	case len(removed) == 0:
+	case removal == RemovalKeep:
+		fmt.Fprintf(out, "Sync: %d tracks were removed from the playlist (use --remove trash|delete to clean them up).\n", len(removed))
+		// Still recorded, so a later sync with --remove can clean them up
+		listed = append(listed, removed...)
+	default:
+		for _, key := range removed {
+			e, ok := store.Get(key)
+			if !ok || shared[key] {
+				continue
+			}
+			if e.Path != "" {
+				if err := removeTrackFile(e.Path, outputDir, removal); err != nil {
+					log.Printf("Error removing '%s': %v\n", e.Path, err)
+					finalErr = err
+					listed = append(listed, key)
+					continue
+				}
+			}
+			if err := store.Remove(key); err != nil {
+				return fmt.Errorf("failed to update state: %w", err)
+			}
+			log.Printf("Removed '%s' (no longer in playlist)\n", filepath.Base(e.Path))
+		}
+	}
+
+	if err := store.SetPlaylistTracks(playlistID, listed); err != nil {
+		return fmt.Errorf("failed to update state: %w", err)
+	}
+	return finalErr
+}
+
+func removeTrackFile(path string, outputDir string, removal RemovalMode) error {
+	// The sidecar lyrics go away with their track, next to it in the trash
+	dest := trashPath(path, outputDir)
+	moves := map[string]string{path: dest, lrcPath(path): lrcPath(dest)}
+	for _, p := range []string{path, lrcPath(path)} {
+		if _, err := os.Stat(p); errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+
+		if removal == RemovalDelete {
+			if err := os.Remove(p); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := os.MkdirAll(filepath.Dir(moves[p]), 0755); err != nil {
+			return fmt.Errorf("error creating trash directory: %w", err)
+		}
+		if err := os.Rename(p, moves[p]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// trashPath returns where the track at p goes in the trash folder: its path relative to
+// outputDir, so tracks with the same name in different folders stay apart, numbered if a
+// track (or its lyrics) trashed earlier already has the name.
+func trashPath(p string, outputDir string) string {
+	rel, err := filepath.Rel(outputDir, p)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		rel = filepath.Base(p)
+	}
+	dest := filepath.Join(outputDir, TrashDirName, rel)
+
+	ext := filepath.Ext(dest)
+	base := strings.TrimSuffix(dest, ext)
+	for i := 2; ; i++ {
+		if !fileExists(dest) && !fileExists(lrcPath(dest)) {
+			return dest
+		}
+		dest = fmt.Sprintf("%s (%d)%s", base, i, ext)
+	}
+}
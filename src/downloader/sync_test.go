@@ -0,0 +1,138 @@
+package downloader
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"playlist-download/src/state"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+func testTrack(id string) spotify.FullTrack {
+	return spotify.FullTrack{SimpleTrack: spotify.SimpleTrack{ID: spotify.ID(id), Name: id}}
+}
+
+func writeTestFile(t *testing.T, path string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(filepath.Base(path)), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPruneRemovedTracks(t *testing.T) {
+	tests := []struct {
+		name         string
+		removal      RemovalMode
+		wantKept     bool     // the removed track's file is still in place
+		wantTrashed  bool     // the removed track's file and lyrics are in the trash
+		wantRecorded []string // tracks recorded for the next sync
+	}{
+		{name: "keep", removal: RemovalKeep, wantKept: true, wantRecorded: []string{"kept", "removed", "shared"}},
+		{name: "trash", removal: RemovalTrash, wantTrashed: true, wantRecorded: []string{"kept"}},
+		{name: "delete", removal: RemovalDelete, wantRecorded: []string{"kept"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			store, err := state.Load(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			paths := map[string]string{
+				"kept":    filepath.Join(dir, "Kept.mp3"),
+				"removed": filepath.Join(dir, "Album", "Removed.mp3"),
+				"shared":  filepath.Join(dir, "Shared.mp3"),
+				"other":   filepath.Join(dir, "Other.mp3"),
+			}
+			for id, p := range paths {
+				writeTestFile(t, p)
+				if err := store.Set(state.Entry{TrackID: id, Path: p, Status: state.StatusDone}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			writeTestFile(t, lrcPath(paths["removed"]))
+			if err := store.SetPlaylistTracks("pl", []string{"kept", "removed", "shared"}); err != nil {
+				t.Fatal(err)
+			}
+			// "shared" is still listed by another playlist synced into the folder, "other" was
+			// never in the synced playlist
+			if err := store.SetPlaylistTracks("other-pl", []string{"shared"}); err != nil {
+				t.Fatal(err)
+			}
+
+			var out bytes.Buffer
+			if err := pruneRemovedTracks(store, "pl", []spotify.FullTrack{testTrack("kept")}, dir, tt.removal, &out); err != nil {
+				t.Fatalf("pruneRemovedTracks: %v", err)
+			}
+
+			for _, id := range []string{"kept", "shared", "other"} {
+				if !fileExists(paths[id]) {
+					t.Errorf("%s track file was removed", id)
+				}
+				if _, ok := store.Get(id); !ok {
+					t.Errorf("%s track dropped from the state", id)
+				}
+			}
+
+			removed := paths["removed"]
+			trashed := filepath.Join(dir, TrashDirName, "Album", "Removed.mp3")
+			if got := fileExists(removed); got != tt.wantKept {
+				t.Errorf("removed track file exists = %v, want %v", got, tt.wantKept)
+			}
+			if got := fileExists(lrcPath(removed)); got != tt.wantKept {
+				t.Errorf("removed track lyrics exist = %v, want %v", got, tt.wantKept)
+			}
+			if got := fileExists(trashed) && fileExists(lrcPath(trashed)); got != tt.wantTrashed {
+				t.Errorf("removed track and lyrics in the trash = %v, want %v", got, tt.wantTrashed)
+			}
+			if _, ok := store.Get("removed"); ok != tt.wantKept {
+				t.Errorf("removed track in the state = %v, want %v", ok, tt.wantKept)
+			}
+			if tt.wantKept && !strings.Contains(out.String(), "2 tracks were removed") {
+				t.Errorf("output %q doesn't report the 2 removed tracks", out.String())
+			}
+
+			recorded := store.PlaylistTracks("pl")
+			sort.Strings(recorded)
+			if !reflect.DeepEqual(recorded, tt.wantRecorded) {
+				t.Errorf("recorded tracks = %v, want %v", recorded, tt.wantRecorded)
+			}
+		})
+	}
+}
+
+func TestTrashPath(t *testing.T) {
+	dir := t.TempDir()
+	trash := filepath.Join(dir, TrashDirName)
+	writeTestFile(t, filepath.Join(trash, "Album", "Song.mp3"))
+	writeTestFile(t, filepath.Join(trash, "Lyrics.lrc"))
+
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{name: "free name", path: filepath.Join(dir, "Other", "Song.mp3"), want: filepath.Join(trash, "Other", "Song.mp3")},
+		{name: "track trashed before", path: filepath.Join(dir, "Album", "Song.mp3"), want: filepath.Join(trash, "Album", "Song (2).mp3")},
+		{name: "lyrics trashed before", path: filepath.Join(dir, "Lyrics.mp3"), want: filepath.Join(trash, "Lyrics (2).mp3")},
+		{name: "outside the output directory", path: filepath.Join(filepath.Dir(dir), "Elsewhere.mp3"), want: filepath.Join(trash, "Elsewhere.mp3")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := trashPath(tt.path, dir); got != tt.want {
+				t.Errorf("trashPath(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OutputFormat is how a run reports what it is doing.
+type OutputFormat string
+
+const (
+	// OutputText prints human readable logs and the progress view
+	OutputText OutputFormat = ""
+	// OutputJSON prints one JSON event per line on stdout
+	OutputJSON OutputFormat = "json"
+)
+
+func ParseOutputFormat(input string) (OutputFormat, error) {
+	input = strings.ToLower(input)
+	switch input {
+	case "", "text":
+		return OutputText, nil
+	case "json", "ndjson":
+		return OutputJSON, nil
+	default:
+		return OutputText, fmt.Errorf("invalid output format: '%s' (valid: text, json)", input)
+	}
+}
+
+type Type string
+
+const (
+	RunStarted       Type = "run_started"
+	TrackQueued      Type = "track_queued"
+	MatchChosen      Type = "match_chosen"
+	DownloadProgress Type = "download_progress"
+	Tagged           Type = "tagged"
+	Skipped          Type = "skipped"
+	Failed           Type = "failed"
+	RunFinished      Type = "run_finished"
+)
+
+// Event is a single line of the JSON output. Only the fields that make sense for its
+// type are set: track events carry the track, run events the run totals.
+type Event struct {
+	Type Type      `json:"type"`
+	Time time.Time `json:"time"`
+
+	// Index is the 1-based position of the track in the list being downloaded
+	Index   int    `json:"index,omitempty"`
+	TrackID string `json:"track_id,omitempty"`
+	Artist  string `json:"artist,omitempty"`
+	Title   string `json:"title,omitempty"`
+
+	VideoID    string  `json:"video_id,omitempty"`
+	VideoTitle string  `json:"video_title,omitempty"`
+	Score      float64 `json:"score,omitempty"`
+	// Source tells how the video was chosen: search or override
+	Source  string  `json:"source,omitempty"`
+	Percent float64 `json:"percent,omitempty"`
+	Path    string  `json:"path,omitempty"`
+	// Reason explains a skipped track
+	Reason   string  `json:"reason,omitempty"`
+	Category string  `json:"category,omitempty"`
+	Error    string  `json:"error,omitempty"`
+	Attempts int     `json:"attempts,omitempty"`
+	Seconds  float64 `json:"seconds,omitempty"`
+
+	OutputDir string  `json:"output_dir,omitempty"`
+	Total     int     `json:"total,omitempty"`
+	Workers   int     `json:"workers,omitempty"`
+	DryRun    bool    `json:"dry_run,omitempty"`
+	Counts    *Counts `json:"counts,omitempty"`
+}
+
+// Counts are the totals of a finished run.
+type Counts struct {
+	Done        int `json:"done"`
+	Skipped     int `json:"skipped"`
+	Failed      int `json:"failed"`
+	Interrupted int `json:"interrupted"`
+}
+
+// Emitter writes the events as newline-delimited JSON. It is safe for concurrent use;
+// a nil *Emitter drops every event.
+type Emitter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func New(w io.Writer) *Emitter {
+	return &Emitter{w: w}
+}
+
+// Enabled reports whether the events are written somewhere. When they are, the human
+// readable output moves out of their way.
+func (e *Emitter) Enabled() bool {
+	return e != nil
+}
+
+// Emit writes ev, stamped with the current time, as a single line.
+func (e *Emitter) Emit(ev Event) {
+	if e == nil {
+		return
+	}
+	ev.Time = time.Now().UTC()
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.w.Write(append(data, '\n'))
+}
@@ -0,0 +1,313 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+// Field is a track detail that can be read from a CSV column.
+type Field struct {
+	Name string
+	// Headers are the column names looked for when no mapping is given, the Exportify ones first
+	Headers []string
+}
+
+// Fields lists the supported fields. title and artist are required, the others are optional.
+var Fields = []Field{
+	{Name: "title", Headers: []string{"Track Name", "Title", "Name", "Song"}},
+	{Name: "artist", Headers: []string{"Artist Name(s)", "Artist Names", "Artists", "Artist"}},
+	{Name: "album", Headers: []string{"Album Name", "Album"}},
+	{Name: "album_artist", Headers: []string{"Album Artist Name(s)", "Album Artist"}},
+	{Name: "release_date", Headers: []string{"Album Release Date", "Release Date", "Date", "Year"}},
+	{Name: "duration_ms", Headers: []string{"Track Duration (ms)", "Duration (ms)", "Duration_ms"}},
+	{Name: "duration", Headers: []string{"Duration", "Length", "Time"}},
+	{Name: "isrc", Headers: []string{"ISRC"}},
+	{Name: "id", Headers: []string{"Track URI", "Spotify ID", "Track ID", "URI"}},
+	{Name: "album_id", Headers: []string{"Album URI", "Album ID"}},
+	{Name: "track_number", Headers: []string{"Track Number"}},
+	{Name: "disc_number", Headers: []string{"Disc Number"}},
+	{Name: "image", Headers: []string{"Album Image URL"}},
+}
+
+// Columns maps field names to the CSV column holding them, for the CSV files not in the
+// Exportify format. Fields left out are looked for under their usual names.
+type Columns map[string]string
+
+// ParseColumns parses a mapping like "title=Song,artist=Performer,duration=Length".
+func ParseColumns(s string) (Columns, error) {
+	columns := make(Columns)
+	if strings.TrimSpace(s) == "" {
+		return columns, nil
+	}
+	for _, part := range strings.Split(s, ",") {
+		name, column, ok := strings.Cut(part, "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		column = strings.TrimSpace(column)
+		if !ok || name == "" || column == "" {
+			return nil, fmt.Errorf("invalid column mapping '%s': expected field=column", strings.TrimSpace(part))
+		}
+		if !isField(name) {
+			return nil, fmt.Errorf("unknown CSV field '%s' (valid: %s)", name, fieldNames())
+		}
+		columns[name] = column
+	}
+	return columns, nil
+}
+
+// LoadCSV reads the tracks of the CSV file at path, see ReadCSV.
+func LoadCSV(path string, columns Columns) ([]spotify.FullTrack, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open CSV file: %w", err)
+	}
+	defer f.Close()
+
+	tracks, err := ReadCSV(f, columns)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV file %s: %w", path, err)
+	}
+	return tracks, nil
+}
+
+// ReadCSV reads one track per row of a CSV file with a header line, e.g. a playlist exported
+// by Exportify. The delimiter (comma, semicolon or tab) is detected from the header. Rows
+// without a title are skipped; values that can't be parsed (e.g. a duration) are ignored.
+func ReadCSV(r io.Reader, columns Columns) ([]spotify.FullTrack, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	// Spreadsheet programs often start the file with a byte order mark
+	data = bytes.TrimPrefix(data, []byte("\ufeff"))
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comma = detectDelimiter(data)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("the file is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+	index, err := columnIndex(header, columns)
+	if err != nil {
+		return nil, err
+	}
+	// Exportify joins the artists with commas; in other files a comma may be part of a
+	// name ("Earth, Wind & Fire"), so only ";" separates the artists there
+	commaSeparated := make(map[string]bool)
+	for field, i := range index {
+		commaSeparated[field] = exportifyArtistHeaders[strings.ToLower(strings.TrimSpace(header[i]))]
+	}
+
+	var tracks []spotify.FullTrack
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+
+		value := func(field string) string {
+			i, ok := index[field]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+		if value("title") == "" {
+			if strings.TrimSpace(strings.Join(record, "")) != "" {
+				log.Printf("Skipping line %d of the CSV file: no title", line)
+			}
+			continue
+		}
+		tracks = append(tracks, newTrack(value, commaSeparated, line))
+	}
+	return tracks, nil
+}
+
+// newTrack builds a track from the values of a CSV row. commaSeparated tells the artist
+// fields whose names are separated by commas.
+func newTrack(value func(field string) string, commaSeparated map[string]bool, line int) spotify.FullTrack {
+	var track spotify.FullTrack
+	track.Name = value("title")
+	track.Artists = splitArtists(value("artist"), commaSeparated["artist"])
+	track.ID = spotify.ID(spotifyID(value("id")))
+	if isrc := value("isrc"); isrc != "" {
+		track.ExternalIDs = map[string]string{"isrc": strings.ToUpper(isrc)}
+	}
+
+	track.Album.Name = value("album")
+	track.Album.ID = spotify.ID(spotifyID(value("album_id")))
+	track.Album.Artists = splitArtists(value("album_artist"), commaSeparated["album_artist"])
+	if date := value("release_date"); date != "" {
+		track.Album.ReleaseDate = date
+		track.Album.ReleaseDatePrecision = datePrecision(date)
+	}
+	if url := value("image"); url != "" {
+		track.Album.Images = []spotify.Image{{URL: url}}
+	}
+
+	if v := value("duration_ms"); v != "" {
+		if ms, err := strconv.ParseFloat(v, 64); err == nil && ms >= 0 {
+			track.Duration = spotify.Numeric(ms)
+		} else {
+			log.Printf("Line %d of the CSV file: invalid duration '%s', ignored", line, v)
+		}
+	} else if v := value("duration"); v != "" {
+		if seconds, err := ParseDuration(v); err == nil {
+			track.Duration = spotify.Numeric(seconds * 1000)
+		} else {
+			log.Printf("Line %d of the CSV file: invalid duration '%s', ignored", line, v)
+		}
+	}
+	if n, err := strconv.Atoi(value("track_number")); err == nil {
+		track.TrackNumber = spotify.Numeric(n)
+	}
+	if n, err := strconv.Atoi(value("disc_number")); err == nil {
+		track.DiscNumber = spotify.Numeric(n)
+	}
+	return track
+}
+
+// columnIndex finds the column of every field in header. Mapped fields must be there,
+// title and artist must be found one way or the other.
+func columnIndex(header []string, columns Columns) (map[string]int, error) {
+	positions := make(map[string]int, len(header))
+	for i, h := range header {
+		h = strings.ToLower(strings.TrimSpace(h))
+		if _, ok := positions[h]; !ok {
+			positions[h] = i
+		}
+	}
+
+	index := make(map[string]int)
+	for _, f := range Fields {
+		if column, ok := columns[f.Name]; ok {
+			i, found := positions[strings.ToLower(column)]
+			if !found {
+				return nil, fmt.Errorf("column '%s' for %s not found (columns: %s)", column, f.Name, strings.Join(header, ", "))
+			}
+			index[f.Name] = i
+			continue
+		}
+		for _, h := range f.Headers {
+			if i, found := positions[strings.ToLower(h)]; found {
+				index[f.Name] = i
+				break
+			}
+		}
+	}
+
+	for _, required := range []string{"title", "artist"} {
+		if _, ok := index[required]; !ok {
+			return nil, fmt.Errorf("no %s column found (columns: %s): map it with %s=<column>", required, strings.Join(header, ", "), required)
+		}
+	}
+	return index, nil
+}
+
+// detectDelimiter picks the most frequent of comma, semicolon and tab in the first line.
+func detectDelimiter(data []byte) rune {
+	first, _, _ := bytes.Cut(data, []byte("\n"))
+	delimiter, best := ',', 0
+	for _, d := range []rune{',', ';', '\t'} {
+		if n := bytes.Count(first, []byte(string(d))); n > best {
+			delimiter, best = d, n
+		}
+	}
+	return delimiter
+}
+
+// exportifyArtistHeaders are the lowercase Exportify columns listing artists separated by commas.
+var exportifyArtistHeaders = map[string]bool{
+	"artist name(s)":       true,
+	"album artist name(s)": true,
+}
+
+// splitArtists splits the artist names of a cell. Names are separated by ";" or, when
+// there is none and commas is true, by ",". Otherwise the cell is a single name.
+func splitArtists(s string, commas bool) []spotify.SimpleArtist {
+	names := []string{s}
+	switch {
+	case strings.Contains(s, ";"):
+		names = strings.Split(s, ";")
+	case commas:
+		names = strings.Split(s, ",")
+	}
+	var artists []spotify.SimpleArtist
+	for _, name := range names {
+		if name = strings.TrimSpace(name); name != "" {
+			artists = append(artists, spotify.SimpleArtist{Name: name})
+		}
+	}
+	return artists
+}
+
+// spotifyID returns the ID out of a Spotify URI (spotify:track:ID), an open.spotify.com URL or a bare ID.
+func spotifyID(s string) string {
+	s, _, _ = strings.Cut(s, "?")
+	s = strings.TrimRight(s, "/")
+	if i := strings.LastIndexAny(s, ":/"); i >= 0 {
+		s = s[i+1:]
+	}
+	return s
+}
+
+// ParseDuration parses a duration like "3:45", "1:02:03" or a number of seconds.
+func ParseDuration(s string) (int, error) {
+	seconds := 0
+	for _, part := range strings.Split(strings.TrimSpace(s), ":") {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid duration '%s'", s)
+		}
+		seconds = seconds*60 + n
+	}
+	return seconds, nil
+}
+
+// datePrecision returns the Spotify precision of a release date: year, month or day.
+func datePrecision(date string) string {
+	switch len(date) {
+	case 4:
+		return "year"
+	case 7:
+		return "month"
+	default:
+		return "day"
+	}
+}
+
+func isField(name string) bool {
+	for _, f := range Fields {
+		if f.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func fieldNames() string {
+	names := make([]string, 0, len(Fields))
+	for _, f := range Fields {
+		names = append(names, f.Name)
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,177 @@
+package importer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+func artistNames(artists []spotify.SimpleArtist) []string {
+	var names []string
+	for _, a := range artists {
+		names = append(names, a.Name)
+	}
+	return names
+}
+
+func TestReadCSVExportify(t *testing.T) {
+	data := "\ufeff" + `"Track URI","Track Name","Album URI","Album Name","Artist Name(s)","Album Artist Name(s)","Album Release Date","Album Image URL","Disc Number","Track Number","Track Duration (ms)","ISRC"
+"spotify:track:4cOdK2wGLETKBW3PvgPWqT","Never Gonna Give You Up","spotify:album:6XhjNHCyCDyyGJRM5mg40G","Whenever You Need Somebody","Rick Astley","Rick Astley","1987-11-12","https://i.scdn.co/image/cover","1","1","213573","gbarl9300135"
+"spotify:track:1","Feel Good Inc.","spotify:album:2","Demon Days","Gorillaz,De La Soul","Gorillaz","2005","","1","6","221000",""
+"","","","","","","","","","","",""
+`
+	tracks, err := ReadCSV(strings.NewReader(data), nil)
+	if err != nil {
+		t.Fatalf("ReadCSV: %v", err)
+	}
+	if len(tracks) != 2 {
+		t.Fatalf("got %d tracks, want 2", len(tracks))
+	}
+
+	rick := tracks[0]
+	if rick.ID != "4cOdK2wGLETKBW3PvgPWqT" || rick.Album.ID != "6XhjNHCyCDyyGJRM5mg40G" {
+		t.Errorf("IDs %q / %q, want the ones of the URIs", rick.ID, rick.Album.ID)
+	}
+	if rick.Name != "Never Gonna Give You Up" || rick.Album.Name != "Whenever You Need Somebody" {
+		t.Errorf("title %q, album %q", rick.Name, rick.Album.Name)
+	}
+	if rick.Duration != 213573 || rick.TrackNumber != 1 || rick.DiscNumber != 1 {
+		t.Errorf("duration %d, track %d, disc %d, want 213573, 1, 1", rick.Duration, rick.TrackNumber, rick.DiscNumber)
+	}
+	if rick.ExternalIDs["isrc"] != "GBARL9300135" {
+		t.Errorf("ISRC %q, want GBARL9300135", rick.ExternalIDs["isrc"])
+	}
+	if rick.Album.ReleaseDate != "1987-11-12" || rick.Album.ReleaseDatePrecision != "day" {
+		t.Errorf("release date %q (%s)", rick.Album.ReleaseDate, rick.Album.ReleaseDatePrecision)
+	}
+	if len(rick.Album.Images) != 1 || rick.Album.Images[0].URL != "https://i.scdn.co/image/cover" {
+		t.Errorf("images %v, want the cover URL", rick.Album.Images)
+	}
+
+	feel := tracks[1]
+	if got := artistNames(feel.Artists); !reflect.DeepEqual(got, []string{"Gorillaz", "De La Soul"}) {
+		t.Errorf("artists %q, want Gorillaz and De La Soul: Exportify separates them with commas", got)
+	}
+	if feel.Album.ReleaseDatePrecision != "year" || feel.ExternalIDs != nil {
+		t.Errorf("precision %q, external IDs %v, want year and none", feel.Album.ReleaseDatePrecision, feel.ExternalIDs)
+	}
+}
+
+func TestReadCSVGeneric(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        string
+		columns     string
+		wantTitles  []string
+		wantArtists [][]string
+		wantSeconds []int
+		wantErr     string
+	}{
+		{
+			name: "comma inside an artist name",
+			data: "Title,Artist,Duration\n" +
+				"Yonkers,\"Tyler, The Creator\",4:09\n" +
+				"September,\"Earth, Wind & Fire\",215\n",
+			wantTitles:  []string{"Yonkers", "September"},
+			wantArtists: [][]string{{"Tyler, The Creator"}, {"Earth, Wind & Fire"}},
+			wantSeconds: []int{249, 215},
+		},
+		{
+			name:        "semicolon separated artists",
+			data:        "Title,Artist\nSong,Artist A; Artist B\n",
+			wantTitles:  []string{"Song"},
+			wantArtists: [][]string{{"Artist A", "Artist B"}},
+			wantSeconds: []int{0},
+		},
+		{
+			name:        "semicolon delimiter",
+			data:        "Title;Artist;Album\nSong;Artist, Jr.;Album\n",
+			wantTitles:  []string{"Song"},
+			wantArtists: [][]string{{"Artist, Jr."}},
+			wantSeconds: []int{0},
+		},
+		{
+			name:        "tab separated, invalid duration ignored",
+			data:        "Name\tArtists\tLength\nSong\tArtist\tsoon\n",
+			wantTitles:  []string{"Song"},
+			wantArtists: [][]string{{"Artist"}},
+			wantSeconds: []int{0},
+		},
+		{
+			name:        "mapped columns",
+			data:        "Song,Performer,Length\nSong,\"Tyler, The Creator\",1:00:00\n",
+			columns:     "title=Song,artist=Performer,duration=Length",
+			wantTitles:  []string{"Song"},
+			wantArtists: [][]string{{"Tyler, The Creator"}},
+			wantSeconds: []int{3600},
+		},
+		{
+			name:    "mapped column missing",
+			data:    "Title,Artist\nSong,Artist\n",
+			columns: "duration=Length",
+			wantErr: "column 'Length' for duration not found",
+		},
+		{
+			name:    "no artist column",
+			data:    "Title,Performer\nSong,Artist\n",
+			wantErr: "no artist column found",
+		},
+		{
+			name:    "empty file",
+			data:    "",
+			wantErr: "the file is empty",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			columns, err := ParseColumns(tt.columns)
+			if err != nil {
+				t.Fatalf("ParseColumns(%q): %v", tt.columns, err)
+			}
+			tracks, err := ReadCSV(strings.NewReader(tt.data), columns)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReadCSV: %v", err)
+			}
+
+			var titles []string
+			var artists [][]string
+			var seconds []int
+			for _, track := range tracks {
+				titles = append(titles, track.Name)
+				artists = append(artists, artistNames(track.Artists))
+				seconds = append(seconds, int(track.Duration)/1000)
+			}
+			if !reflect.DeepEqual(titles, tt.wantTitles) {
+				t.Errorf("titles = %q, want %q", titles, tt.wantTitles)
+			}
+			if !reflect.DeepEqual(artists, tt.wantArtists) {
+				t.Errorf("artists = %q, want %q", artists, tt.wantArtists)
+			}
+			if !reflect.DeepEqual(seconds, tt.wantSeconds) {
+				t.Errorf("durations = %v, want %v", seconds, tt.wantSeconds)
+			}
+		})
+	}
+}
+
+func TestParseColumns(t *testing.T) {
+	if _, err := ParseColumns("genre=Style"); err == nil || !strings.Contains(err.Error(), "unknown CSV field 'genre'") {
+		t.Errorf("unknown field: error = %v", err)
+	}
+	if _, err := ParseColumns("title"); err == nil || !strings.Contains(err.Error(), "expected field=column") {
+		t.Errorf("missing column: error = %v", err)
+	}
+	columns, err := ParseColumns(" Title = Song , artist=Performer")
+	if err != nil || !reflect.DeepEqual(columns, Columns{"title": "Song", "artist": "Performer"}) {
+		t.Errorf("ParseColumns = %v, %v", columns, err)
+	}
+}
@@ -0,0 +1,145 @@
+package importer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+var (
+	// numberingRegex matches a position at the start of a line: "01. ", "1) "
+	numberingRegex = regexp.MustCompile(`^\d{1,3}\s*[.)]\s+`)
+	// dashNumberingRegex matches a position followed by a dash: "01 - Artist - Title"
+	dashNumberingRegex = regexp.MustCompile(`^\d{1,3}\s+[-–—]\s+`)
+	// timestampRegex matches a start time at the start of a line, as in setlists: "[00:12:30] ", "12:30 "
+	timestampRegex = regexp.MustCompile(`^[\[(]?\d{1,2}:\d{2}(?::\d{2})?[\])]?\s+`)
+	// durationRegex matches a duration at the end of a line: " [3:45]", " (3:45)", " 3:45"
+	durationRegex = regexp.MustCompile(`\s*[\[(]?(\d{1,2}:\d{2}(?::\d{2})?)[\])]?$`)
+	// separatorRegex matches the dash between artist and title
+	separatorRegex = regexp.MustCompile(`\s+[-–—]\s+`)
+	// featRegex matches the featured artists, in parentheses or not: "(feat. X)", "ft. X & Y"
+	featRegex = regexp.MustCompile(`(?i)\s*[(\[]?\b(?:feat\.?|ft\.?|featuring)\s+([^)\]]+)[)\]]?`)
+	// namesRegex matches the separators of a list of names: "X, Y & Z"
+	namesRegex = regexp.MustCompile(`\s*(?:,|&|\band\b)\s*`)
+)
+
+// LoadText reads the tracks listed in the text file at path, "-" for stdin, see ReadText.
+func LoadText(path string) ([]spotify.FullTrack, error) {
+	if path == "-" {
+		return ReadText(os.Stdin)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open track list: %w", err)
+	}
+	defer f.Close()
+
+	tracks, err := ReadText(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read track list %s: %w", path, err)
+	}
+	return tracks, nil
+}
+
+// ReadText reads a pasted track list, one "Artist - Title" per line, e.g.
+//
+//	Daft Punk – Get Lucky (feat. Pharrell Williams)
+//	01. Rick Astley - Never Gonna Give You Up [3:33]
+//
+// Positions, start times and durations around the track are recognized; empty lines and
+// "#" comments are ignored, lines without an "Artist - Title" are reported and skipped.
+func ReadText(r io.Reader) ([]spotify.FullTrack, error) {
+	var tracks []spotify.FullTrack
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		track, ok := ParseTrackLine(text)
+		if !ok {
+			log.Printf("Skipping line %d: '%s' is not 'Artist - Title'", line, text)
+			continue
+		}
+		tracks = append(tracks, track)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return tracks, nil
+}
+
+// ParseTrackLine parses a line like "01. Artist – Title (feat. X) [3:45]" into a track
+// with its artists, title and, if given, duration. ok is false without an artist or a title.
+func ParseTrackLine(line string) (track spotify.FullTrack, ok bool) {
+	line = strings.TrimSpace(line)
+	line = timestampRegex.ReplaceAllString(line, "")
+	line = numberingRegex.ReplaceAllString(line, "")
+	// "01 - Artist - Title", but not "50 Cent - In Da Club"
+	if trimmed := dashNumberingRegex.ReplaceAllString(line, ""); separatorRegex.MatchString(trimmed) {
+		line = trimmed
+	}
+	if m := durationRegex.FindStringSubmatchIndex(line); m != nil {
+		if seconds, err := ParseDuration(line[m[2]:m[3]]); err == nil {
+			track.Duration = spotify.Numeric(seconds * 1000)
+			line = line[:m[0]]
+		}
+	}
+
+	sep := separatorRegex.FindStringIndex(line)
+	if sep == nil {
+		return track, false
+	}
+	artist := strings.TrimSpace(line[:sep[0]])
+	title := trimQuotes(strings.TrimSpace(line[sep[1]:]))
+	if artist == "" || title == "" {
+		return track, false
+	}
+
+	// "Artist feat. X - Title": X goes with the other artists
+	var featured []string
+	if m := featRegex.FindStringSubmatchIndex(artist); m != nil {
+		featured = append(featured, splitNames(artist[m[2]:m[3]])...)
+		artist = strings.TrimSpace(artist[:m[0]])
+	}
+	// "Artist - Title (feat. X)": the title keeps it, as Spotify titles do
+	if m := featRegex.FindStringSubmatch(title); m != nil {
+		featured = append(featured, splitNames(m[1])...)
+	}
+
+	track.Name = title
+	track.Artists = []spotify.SimpleArtist{{Name: artist}}
+	for _, name := range featured {
+		track.Artists = append(track.Artists, spotify.SimpleArtist{Name: name})
+	}
+	return track, true
+}
+
+// splitNames splits a list of featured artists: "X, Y & Z".
+func splitNames(s string) []string {
+	var names []string
+	for _, part := range namesRegex.Split(s, -1) {
+		if part = strings.TrimSpace(part); part != "" {
+			names = append(names, part)
+		}
+	}
+	return names
+}
+
+// trimQuotes removes the quotes around a title: "Title" or “Title”.
+func trimQuotes(s string) string {
+	for _, q := range [][2]string{{`"`, `"`}, {"“", "”"}, {"'", "'"}} {
+		if len(s) > 2 && strings.HasPrefix(s, q[0]) && strings.HasSuffix(s, q[1]) {
+			return strings.TrimSpace(s[len(q[0]) : len(s)-len(q[1])])
+		}
+	}
+	return s
+}
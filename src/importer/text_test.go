@@ -0,0 +1,92 @@
+package importer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTrackLine(t *testing.T) {
+	tests := []struct {
+		name        string
+		line        string
+		wantOK      bool
+		wantTitle   string
+		wantArtists []string
+		wantSeconds int
+	}{
+		{name: "artist - title", line: "Rick Astley - Never Gonna Give You Up", wantOK: true, wantTitle: "Never Gonna Give You Up", wantArtists: []string{"Rick Astley"}},
+		{name: "en dash", line: "Daft Punk – Get Lucky", wantOK: true, wantTitle: "Get Lucky", wantArtists: []string{"Daft Punk"}},
+		{name: "dot numbering", line: "01. Rick Astley - Never Gonna Give You Up", wantOK: true, wantTitle: "Never Gonna Give You Up", wantArtists: []string{"Rick Astley"}},
+		{name: "parenthesis numbering", line: "12) Artist - Title", wantOK: true, wantTitle: "Title", wantArtists: []string{"Artist"}},
+		{name: "dash numbering", line: "03 - Artist - Title", wantOK: true, wantTitle: "Title", wantArtists: []string{"Artist"}},
+		{name: "number in the artist name", line: "50 Cent - In Da Club", wantOK: true, wantTitle: "In Da Club", wantArtists: []string{"50 Cent"}},
+		{name: "dash in the artist name", line: "Blink-182 - All the Small Things", wantOK: true, wantTitle: "All the Small Things", wantArtists: []string{"Blink-182"}},
+		{name: "bracketed timestamp", line: "[00:12:30] Artist - Title", wantOK: true, wantTitle: "Title", wantArtists: []string{"Artist"}},
+		{name: "bare timestamp", line: "12:30 Artist - Title", wantOK: true, wantTitle: "Title", wantArtists: []string{"Artist"}},
+		{name: "bracketed duration", line: "Artist - Title [3:45]", wantOK: true, wantTitle: "Title", wantArtists: []string{"Artist"}, wantSeconds: 225},
+		{name: "parenthesized duration", line: "Artist - Title (1:02:03)", wantOK: true, wantTitle: "Title", wantArtists: []string{"Artist"}, wantSeconds: 3723},
+		{name: "bare duration", line: "Artist - Title 4:09", wantOK: true, wantTitle: "Title", wantArtists: []string{"Artist"}, wantSeconds: 249},
+		{
+			name:        "numbering, feat. in the title and duration",
+			line:        "01. Daft Punk – Get Lucky (feat. Pharrell Williams & Nile Rodgers) [4:08]",
+			wantOK:      true,
+			wantTitle:   "Get Lucky (feat. Pharrell Williams & Nile Rodgers)",
+			wantArtists: []string{"Daft Punk", "Pharrell Williams", "Nile Rodgers"},
+			wantSeconds: 248,
+		},
+		{
+			name:        "ft. in the artist",
+			line:        "Artist ft. X, Y and Z - Title",
+			wantOK:      true,
+			wantTitle:   "Title",
+			wantArtists: []string{"Artist", "X", "Y", "Z"},
+		},
+		{name: "quoted title", line: `Artist - "Title"`, wantOK: true, wantTitle: "Title", wantArtists: []string{"Artist"}},
+		{name: "curly quoted title", line: "Artist - “Title”", wantOK: true, wantTitle: "Title", wantArtists: []string{"Artist"}},
+		{name: "no separator", line: "Just a title", wantOK: false},
+		{name: "dash without spaces", line: "Artist-Title", wantOK: false},
+		{name: "no title", line: "Artist - [3:45]", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			track, ok := ParseTrackLine(tt.line)
+			if ok != tt.wantOK {
+				t.Fatalf("ParseTrackLine(%q) ok = %v, want %v", tt.line, ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if track.Name != tt.wantTitle {
+				t.Errorf("title = %q, want %q", track.Name, tt.wantTitle)
+			}
+			if got := artistNames(track.Artists); !reflect.DeepEqual(got, tt.wantArtists) {
+				t.Errorf("artists = %q, want %q", got, tt.wantArtists)
+			}
+			if got := int(track.Duration) / 1000; got != tt.wantSeconds {
+				t.Errorf("duration = %ds, want %ds", got, tt.wantSeconds)
+			}
+		})
+	}
+}
+
+func TestReadText(t *testing.T) {
+	input := `# Setlist
+01. Artist One - First
+
+Not a track
+02. Artist Two - Second [3:00]
+`
+	tracks, err := ReadText(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ReadText: %v", err)
+	}
+	var titles []string
+	for _, track := range tracks {
+		titles = append(titles, track.Name)
+	}
+	if !reflect.DeepEqual(titles, []string{"First", "Second"}) {
+		t.Errorf("titles = %q, want First and Second", titles)
+	}
+}
@@ -0,0 +1,210 @@
+package lyrics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+// DefaultBaseURL is the public LRCLIB instance.
+const DefaultBaseURL = "https://lrclib.net"
+
+// ErrNotFound is returned when the server has no lyrics for the track.
+var ErrNotFound = errors.New("lyrics not found")
+
+// durationTolerance is how far (in seconds) a search result may be from the track duration.
+const durationTolerance = 3
+
+// Lyrics holds the lyrics of a track. Synced is in LRC format ("[mm:ss.xx] line").
+type Lyrics struct {
+	Plain        string
+	Synced       string
+	Instrumental bool
+}
+
+// Line is a line of synced lyrics.
+type Line struct {
+	Time time.Duration
+	Text string
+}
+
+// Client fetches lyrics from an LRCLIB-compatible HTTP API.
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+// NewClient returns a client for the API at baseURL, DefaultBaseURL if empty.
+func NewClient(baseURL string) *Client {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return &Client{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		HTTPClient: &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// record is a lyrics record as returned by the API.
+type record struct {
+	TrackName    string  `json:"trackName"`
+	ArtistName   string  `json:"artistName"`
+	AlbumName    string  `json:"albumName"`
+	Duration     float64 `json:"duration"`
+	Instrumental bool    `json:"instrumental"`
+	PlainLyrics  string  `json:"plainLyrics"`
+	SyncedLyrics string  `json:"syncedLyrics"`
+}
+
+// Get returns the lyrics of track. It asks for the exact track first (title, artist, album
+// and duration) and falls back to a search by title and artist, keeping the first result
+// with a close enough duration.
+func (c *Client) Get(ctx context.Context, track spotify.FullTrack) (*Lyrics, error) {
+	artist := ""
+	if len(track.Artists) > 0 {
+		artist = track.Artists[0].Name
+	}
+	seconds := int(track.Duration) / 1000
+
+	params := url.Values{}
+	params.Set("track_name", track.Name)
+	params.Set("artist_name", artist)
+	params.Set("album_name", track.Album.Name)
+	params.Set("duration", strconv.Itoa(seconds))
+
+	var exact record
+	err := c.getJSON(ctx, "/api/get", params, &exact)
+	if err == nil {
+		return exact.lyrics()
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+
+	params = url.Values{}
+	params.Set("track_name", track.Name)
+	params.Set("artist_name", artist)
+
+	var results []record
+	if err := c.getJSON(ctx, "/api/search", params, &results); err != nil {
+		return nil, err
+	}
+	for _, r := range results {
+		if seconds > 0 && math.Abs(r.Duration-float64(seconds)) > durationTolerance {
+			continue
+		}
+		if l, err := r.lyrics(); err == nil {
+			return l, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r record) lyrics() (*Lyrics, error) {
+	if !r.Instrumental && strings.TrimSpace(r.PlainLyrics) == "" && strings.TrimSpace(r.SyncedLyrics) == "" {
+		return nil, ErrNotFound
+	}
+	l := &Lyrics{Plain: r.PlainLyrics, Synced: r.SyncedLyrics, Instrumental: r.Instrumental}
+	// Plain lyrics can always be derived from synced ones
+	if strings.TrimSpace(l.Plain) == "" && l.Synced != "" {
+		var lines []string
+		for _, line := range l.Lines() {
+			lines = append(lines, line.Text)
+		}
+		l.Plain = strings.Join(lines, "\n")
+	}
+	return l, nil
+}
+
+func (c *Client) getJSON(ctx context.Context, path string, params url.Values, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURL+path+"?"+params.Encode(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to build lyrics request: %w", err)
+	}
+	// LRCLIB asks clients to identify themselves
+	req.Header.Set("User-Agent", "playlist-download (https://github.com/AlexFalzone/playlist-download)")
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("lyrics request failed: %w", err)
+	}
+	defer func(body io.ReadCloser) {
+		if cErr := body.Close(); cErr != nil {
+			// nothing to do, the body has been read
+		}
+	}(resp.Body)
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("lyrics request failed: status code %d", resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to parse lyrics response: %w", err)
+	}
+	return nil
+}
+
+var lrcTimeRegex = regexp.MustCompile(`\[(\d+):(\d{1,2})(?:[.:](\d{1,3}))?\]`)
+
+// Lines parses the synced lyrics. Lines with more timestamps ("[00:12.00][01:30.00] chorus")
+// appear once per timestamp; the result is sorted by time. Tags like [ar:...] are ignored.
+func (l *Lyrics) Lines() []Line {
+	var lines []Line
+	for _, raw := range strings.Split(l.Synced, "\n") {
+		raw = strings.TrimSpace(raw)
+		stamps := lrcTimeRegex.FindAllStringSubmatchIndex(raw, -1)
+		if len(stamps) == 0 || stamps[0][0] != 0 {
+			continue
+		}
+
+		// Timestamps are all at the start of the line, before the text
+		end := 0
+		var times []time.Duration
+		for _, s := range stamps {
+			if s[0] != end {
+				break
+			}
+			end = s[1]
+			times = append(times, parseLRCTime(raw, s))
+		}
+
+		text := strings.TrimSpace(raw[end:])
+		for _, t := range times {
+			lines = append(lines, Line{Time: t, Text: text})
+		}
+	}
+
+	sort.SliceStable(lines, func(i, j int) bool {
+		return lines[i].Time < lines[j].Time
+	})
+	return lines
+}
+
+func parseLRCTime(line string, match []int) time.Duration {
+	minutes, _ := strconv.Atoi(line[match[2]:match[3]])
+	seconds, _ := strconv.Atoi(line[match[4]:match[5]])
+	d := time.Duration(minutes)*time.Minute + time.Duration(seconds)*time.Second
+
+	if match[6] >= 0 {
+		fraction := line[match[6]:match[7]]
+		// ".5" is half a second, ".05" five hundredths, ".005" five milliseconds
+		ms, _ := strconv.Atoi((fraction + "00")[:3])
+		d += time.Duration(ms) * time.Millisecond
+	}
+	return d
+}
@@ -0,0 +1,190 @@
+package lyrics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+// lrclib plays an LRCLIB server: get answers /api/get, search answers /api/search.
+// A nil answer is a 404.
+type lrclib struct {
+	get    *record
+	search []record
+
+	mu       sync.Mutex
+	requests []*url.URL
+}
+
+func (l *lrclib) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	l.mu.Lock()
+	l.requests = append(l.requests, r.URL)
+	l.mu.Unlock()
+
+	if r.Header.Get("User-Agent") == "" {
+		http.Error(w, "missing User-Agent", http.StatusBadRequest)
+		return
+	}
+	var answer interface{}
+	switch r.URL.Path {
+	case "/api/get":
+		if l.get == nil {
+			http.Error(w, `{"code":404,"name":"TrackNotFound"}`, http.StatusNotFound)
+			return
+		}
+		answer = l.get
+	case "/api/search":
+		answer = l.search
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(answer); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func newTestClient(t *testing.T, server *lrclib) *Client {
+	ts := httptest.NewServer(server)
+	t.Cleanup(ts.Close)
+	return NewClient(ts.URL + "/")
+}
+
+var testTrack = spotify.FullTrack{
+	SimpleTrack: spotify.SimpleTrack{
+		Name:     "Never Gonna Give You Up",
+		Artists:  []spotify.SimpleArtist{{Name: "Rick Astley"}},
+		Duration: 213573,
+	},
+	Album: spotify.SimpleAlbum{Name: "Whenever You Need Somebody"},
+}
+
+func TestGetExact(t *testing.T) {
+	server := &lrclib{get: &record{
+		TrackName:    "Never Gonna Give You Up",
+		PlainLyrics:  "We're no strangers to love",
+		SyncedLyrics: "[00:18.80] We're no strangers to love",
+	}}
+	client := newTestClient(t, server)
+
+	l, err := client.Get(context.Background(), testTrack)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if l.Plain != "We're no strangers to love" || l.Synced != "[00:18.80] We're no strangers to love" {
+		t.Errorf("got %+v", l)
+	}
+
+	if len(server.requests) != 1 {
+		t.Fatalf("got %d requests, want only /api/get", len(server.requests))
+	}
+	query := server.requests[0].Query()
+	want := map[string]string{
+		"track_name":  "Never Gonna Give You Up",
+		"artist_name": "Rick Astley",
+		"album_name":  "Whenever You Need Somebody",
+		"duration":    "213",
+	}
+	for key, value := range want {
+		if query.Get(key) != value {
+			t.Errorf("%s = %q, want %q", key, query.Get(key), value)
+		}
+	}
+}
+
+func TestGetSearchFallback(t *testing.T) {
+	server := &lrclib{search: []record{
+		{TrackName: "Never Gonna Give You Up (Extended)", Duration: 400, PlainLyrics: "extended"},
+		{TrackName: "Never Gonna Give You Up", Duration: 214},
+		{TrackName: "Never Gonna Give You Up", Duration: 212, SyncedLyrics: "[00:18.80] We're no strangers\n[00:22.10] to love"},
+	}}
+	client := newTestClient(t, server)
+
+	l, err := client.Get(context.Background(), testTrack)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	// The extended version is too long and the second result has no lyrics
+	if l.Synced == "" {
+		t.Fatalf("got %+v, want the third result", l)
+	}
+	if l.Plain != "We're no strangers\nto love" {
+		t.Errorf("plain lyrics derived from the synced ones = %q", l.Plain)
+	}
+
+	if len(server.requests) != 2 || server.requests[1].Path != "/api/search" {
+		t.Fatalf("got requests %v, want /api/get then /api/search", server.requests)
+	}
+	if query := server.requests[1].Query(); query.Get("track_name") != "Never Gonna Give You Up" || query.Get("artist_name") != "Rick Astley" {
+		t.Errorf("search query = %v", query)
+	}
+}
+
+func TestGetNotFound(t *testing.T) {
+	server := &lrclib{search: []record{{TrackName: "Something else", Duration: 100, PlainLyrics: "la la"}}}
+	client := newTestClient(t, server)
+
+	if _, err := client.Get(context.Background(), testTrack); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got error %v, want ErrNotFound", err)
+	}
+}
+
+func TestGetInstrumental(t *testing.T) {
+	client := newTestClient(t, &lrclib{get: &record{Instrumental: true}})
+
+	l, err := client.Get(context.Background(), testTrack)
+	if err != nil || !l.Instrumental {
+		t.Errorf("got %+v, %v, want an instrumental track", l, err)
+	}
+}
+
+func TestGetServerError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	_, err := NewClient(ts.URL).Get(context.Background(), testTrack)
+	if err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("got error %v, want a request error", err)
+	}
+}
+
+func TestLines(t *testing.T) {
+	l := &Lyrics{Synced: "[ar:Rick Astley]\n" +
+		"[00:18.80] We're no strangers to love\n" +
+		"[00:43.5][01:30.05]Never gonna give you up\n" +
+		"\n" +
+		"[00:22:10] You know the rules\n" +
+		"[01:02.123] and so do I\n" +
+		"not a lyrics line\n" +
+		"[00:50.00]"}
+
+	want := []Line{
+		{18*time.Second + 800*time.Millisecond, "We're no strangers to love"},
+		{22*time.Second + 100*time.Millisecond, "You know the rules"},
+		{43*time.Second + 500*time.Millisecond, "Never gonna give you up"},
+		{50 * time.Second, ""},
+		{62*time.Second + 123*time.Millisecond, "and so do I"},
+		{90*time.Second + 50*time.Millisecond, "Never gonna give you up"},
+	}
+
+	got := l.Lines()
+	if len(got) != len(want) {
+		t.Fatalf("got %d lines %v, want %d", len(got), got, len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("line %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package naming
+
+import (
+	"fmt"
+	"path/filepath"
+	"playlist-download/src/utils"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+// DefaultTemplate keeps the historical layout: every track directly in the output directory.
+const DefaultTemplate = "{title}"
+
+// Fields lists the placeholders a template can use, with their description.
+var Fields = []struct {
+	Name        string
+	Description string
+}{
+	{"title", "track title"},
+	{"artist", "main artist of the track"},
+	{"artists", "all the artists of the track, comma separated"},
+	{"album", "album name"},
+	{"album_artist", "main artist of the album"},
+	{"album_artists", "all the artists of the album, comma separated"},
+	{"album_type", "album, single or compilation"},
+	{"year", "release year"},
+	{"date", "release date, as precise as Spotify knows it"},
+	{"disc", "disc number"},
+	{"track", "track number on the disc"},
+	{"total_tracks", "number of tracks of the album"},
+	{"isrc", "ISRC code"},
+	{"id", "Spotify track ID"},
+	{"album_id", "Spotify album ID"},
+	{"index", "position in the playlist or album being downloaded, starting from 1"},
+}
+
+var placeholderRegex = regexp.MustCompile(`\{([a-z_]+)(?::([^}]*))?\}`)
+var paddingRegex = regexp.MustCompile(`^0?[1-9]$`)
+
+// Template builds the path of a track, relative to the output directory and without
+// extension, from a pattern like "{album_artist}/{year} - {album}/{disc}-{track:02} {title}".
+// "/" separates folders; every folder and the file name are sanitized separately, so a
+// "/" inside a field (e.g. "AC/DC") can't create unexpected folders.
+type Template struct {
+	pattern    string
+	components []string
+}
+
+// Parse validates a template: every placeholder must be a known field and the only
+// supported format is a numeric width, with a leading 0 for zero padding ({track:02}).
+func Parse(pattern string) (*Template, error) {
+	pattern = strings.TrimSpace(pattern)
+	if pattern == "" {
+		pattern = DefaultTemplate
+	}
+
+	known := make(map[string]bool, len(Fields))
+	for _, f := range Fields {
+		known[f.Name] = true
+	}
+
+	for _, m := range placeholderRegex.FindAllStringSubmatch(pattern, -1) {
+		if !known[m[1]] {
+			return nil, fmt.Errorf("invalid template field: '{%s}' (valid: %s)", m[1], fieldNames())
+		}
+		if m[2] != "" && !paddingRegex.MatchString(m[2]) {
+			return nil, fmt.Errorf("invalid format '%s' for field '{%s}' (valid: a width like 2 or 02)", m[2], m[1])
+		}
+	}
+	if strings.ContainsAny(placeholderRegex.ReplaceAllString(pattern, ""), "{}") {
+		return nil, fmt.Errorf("invalid template: unbalanced braces in '%s'", pattern)
+	}
+
+	var components []string
+	for _, c := range strings.Split(filepath.ToSlash(pattern), "/") {
+		if strings.TrimSpace(c) != "" {
+			components = append(components, c)
+		}
+	}
+	if len(components) == 0 {
+		return nil, fmt.Errorf("invalid template: '%s' has no file name", pattern)
+	}
+
+	return &Template{pattern: pattern, components: components}, nil
+}
+
+// String returns the pattern the template was parsed from.
+func (t *Template) String() string {
+	return t.pattern
+}
+
+// HasFolders reports whether the template puts tracks into folders of their own.
+func (t *Template) HasFolders() bool {
+	return len(t.components) > 1
+}
+
+// Render returns the relative path of track, without extension. index is the 0-based
+// position of the track in the list being downloaded.
+func (t *Template) Render(track spotify.FullTrack, index int) string {
+	values := TrackFields(track, index)
+
+	parts := make([]string, 0, len(t.components))
+	for _, c := range t.components {
+		rendered := placeholderRegex.ReplaceAllStringFunc(c, func(placeholder string) string {
+			m := placeholderRegex.FindStringSubmatch(placeholder)
+			return formatValue(values[m[1]], m[2])
+		})
+		parts = append(parts, sanitizeComponent(rendered))
+	}
+	return filepath.Join(parts...)
+}
+
+// TrackFields returns the value of every template field for track.
+func TrackFields(track spotify.FullTrack, index int) map[string]string {
+	values := map[string]string{
+		"title":         track.Name,
+		"artists":       joinArtists(track.Artists),
+		"album":         track.Album.Name,
+		"album_artists": joinArtists(track.Album.Artists),
+		"album_type":    strings.ToLower(track.Album.AlbumType),
+		"date":          track.Album.ReleaseDate,
+		"disc":          numberOrEmpty(int(track.DiscNumber)),
+		"track":         numberOrEmpty(int(track.TrackNumber)),
+		"total_tracks":  numberOrEmpty(int(track.Album.TotalTracks)),
+		"isrc":          utils.TrackISRC(track),
+		"id":            string(track.ID),
+		"album_id":      string(track.Album.ID),
+		"index":         strconv.Itoa(index + 1),
+	}
+	if len(track.Artists) > 0 {
+		values["artist"] = track.Artists[0].Name
+	}
+	if len(track.Album.Artists) > 0 {
+		values["album_artist"] = track.Album.Artists[0].Name
+	} else {
+		values["album_artist"] = values["artist"]
+	}
+	if len(track.Album.ReleaseDate) >= 4 {
+		values["year"] = track.Album.ReleaseDate[:4]
+	}
+	return values
+}
+
+func joinArtists(artists []spotify.SimpleArtist) string {
+	names := make([]string, 0, len(artists))
+	for _, a := range artists {
+		names = append(names, a.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+func numberOrEmpty(n int) string {
+	if n <= 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
+
+// formatValue pads value to the width in spec: with zeros for "02", with spaces for "2".
+func formatValue(value string, spec string) string {
+	if spec == "" || value == "" {
+		return value
+	}
+	width, _ := strconv.Atoi(strings.TrimPrefix(spec, "0"))
+	pad := " "
+	if strings.HasPrefix(spec, "0") {
+		pad = "0"
+	}
+	if n := width - len([]rune(value)); n > 0 {
+		return strings.Repeat(pad, n) + value
+	}
+	return value
+}
+
+func fieldNames() string {
+	names := make([]string, 0, len(Fields))
+	for _, f := range Fields {
+		names = append(names, f.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// sanitizeComponent makes a rendered folder or file name safe. Empty names (e.g. "{isrc}"
+// for a track without ISRC) and "." or ".." can't be used as path components.
+func sanitizeComponent(name string) string {
+	name = strings.TrimSpace(utils.RemoveIllegalPathChars(name))
+	switch name {
+	case "":
+		return "Unknown"
+	case ".", "..":
+		return "_"
+	}
+	return name
+}
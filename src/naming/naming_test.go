@@ -0,0 +1,130 @@
+package naming
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+func testTrack() spotify.FullTrack {
+	return spotify.FullTrack{
+		SimpleTrack: spotify.SimpleTrack{
+			ID:          "trackid",
+			Name:        "Back In Black",
+			Artists:     []spotify.SimpleArtist{{Name: "AC/DC"}, {Name: "Guest"}},
+			DiscNumber:  1,
+			TrackNumber: 6,
+		},
+		Album: spotify.SimpleAlbum{
+			ID:          "albumid",
+			Name:        "Back In Black",
+			AlbumType:   "Album",
+			ReleaseDate: "1980-07-25",
+			TotalTracks: 10,
+		},
+		ExternalIDs: map[string]string{"isrc": "AUAP08000046"},
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		wantErr string
+	}{
+		{name: "default", pattern: ""},
+		{name: "folders", pattern: "{album_artist}/{year} - {album}/{disc}-{track:02} {title}"},
+		{name: "space padding", pattern: "{index:3} {title}"},
+		{name: "unknown placeholder", pattern: "{genre}/{title}", wantErr: "invalid template field: '{genre}'"},
+		{name: "unsupported format", pattern: "{track:x2} {title}", wantErr: "invalid format 'x2'"},
+		{name: "too wide padding", pattern: "{track:10} {title}", wantErr: "invalid format '10'"},
+		{name: "unbalanced braces", pattern: "{title", wantErr: "unbalanced braces"},
+		{name: "uppercase field", pattern: "{Title}", wantErr: "unbalanced braces"},
+		{name: "no file name", pattern: "/ /", wantErr: "has no file name"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.pattern)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Parse(%q): %v", tt.pattern, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Parse(%q) error = %v, want %q", tt.pattern, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		track   func(*spotify.FullTrack)
+		index   int
+		want    string
+	}{
+		{name: "default", pattern: DefaultTemplate, want: "Back In Black"},
+		{
+			name:    "folders and zero padding",
+			pattern: "{album_artist}/{year} - {album}/{disc}-{track:02} {title}",
+			want:    filepath.Join("AC_DC", "1980 - Back In Black", "1-06 Back In Black"),
+		},
+		{name: "space padding", pattern: "{title} -{index:3}", index: 4, want: "Back In Black -  5"},
+		{name: "padding shorter than the value", pattern: "{total_tracks:1}", want: "10"},
+		{name: "slash in a value stays in the file name", pattern: "{artist} - {title}", want: "AC_DC - Back In Black"},
+		{name: "every artist", pattern: "{artists}", want: "AC_DC, Guest"},
+		{name: "ids", pattern: "{isrc} {id} {album_id}", want: "AUAP08000046 trackid albumid"},
+		{name: "album type lowercase", pattern: "{album_type}/{title}", want: filepath.Join("album", "Back In Black")},
+		{
+			name:    "illegal characters",
+			pattern: "{title}",
+			track:   func(tr *spotify.FullTrack) { tr.Name = `What? "Yes": <No> | Maybe*` },
+			want:    "What_ _Yes__ _No_ _ Maybe_",
+		},
+		{
+			name:    "empty field",
+			pattern: "{isrc}/{title}",
+			track:   func(tr *spotify.FullTrack) { tr.ExternalIDs = nil },
+			want:    filepath.Join("Unknown", "Back In Black"),
+		},
+		{
+			name:    "no padding for an empty field",
+			pattern: "{track:02} {title}",
+			track:   func(tr *spotify.FullTrack) { tr.TrackNumber = 0 },
+			want:    "Back In Black",
+		},
+		{
+			name:    "dot folder",
+			pattern: "{album}/{title}",
+			track:   func(tr *spotify.FullTrack) { tr.Album.Name = ".." },
+			want:    filepath.Join("_", "Back In Black"),
+		},
+		{
+			name:    "album artist falls back to the artist",
+			pattern: "{album_artist}",
+			want:    "AC_DC",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := Parse(tt.pattern)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.pattern, err)
+			}
+			track := testTrack()
+			if tt.track != nil {
+				tt.track(&track)
+			}
+			if got := tmpl.Render(track, tt.index); got != tt.want {
+				t.Errorf("Render(%q) = %q, want %q", tt.pattern, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package overrides
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"playlist-download/src/utils"
+	"regexp"
+	"strings"
+
+	"github.com/zmb3/spotify/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultFileNames are looked up, in order, in the output directory when no file is given.
+var DefaultFileNames = []string{"overrides.yaml", "overrides.yml", "overrides.json"}
+
+// Skip is the override value telling the downloader to leave a track out.
+const Skip = "skip"
+
+// isrcRegex matches an ISRC: country code, registrant code, year and designation code.
+// Spotify track IDs are 22 characters long, so they never match.
+var isrcRegex = regexp.MustCompile(`^[A-Za-z]{2}[A-Za-z0-9]{3}[0-9]{7}$`)
+
+// Override is the manual match of a single track.
+type Override struct {
+	// Key is the Spotify track ID or ISRC the override was found with
+	Key     string
+	VideoID string
+	Skip    bool
+}
+
+// Overrides maps Spotify track IDs (or ISRCs) to a fixed YouTube video or to "skip".
+//
+// The file is a flat YAML or JSON map, for example:
+//
+//	4uLU6hMCjMI75M1A2tKUQC: dQw4w9WgXcQ
+//	USUM71703861: skip
+//	6rqhFgbbKwnb9MLmUQDhG6: https://www.youtube.com/watch?v=fJ9rUzIMcZQ
+type Overrides struct {
+	Path    string
+	entries map[string]Override
+}
+
+// Load reads the overrides file. An empty path looks for one of DefaultFileNames in
+// outputDir and yields empty overrides if none exists.
+func Load(path string, outputDir string) (*Overrides, error) {
+	if path == "" {
+		for _, name := range DefaultFileNames {
+			candidate := filepath.Join(outputDir, name)
+			if _, err := os.Stat(candidate); err == nil {
+				path = candidate
+				break
+			}
+		}
+		if path == "" {
+			return &Overrides{entries: map[string]Override{}}, nil
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read overrides file %s: %w", path, err)
+	}
+
+	// YAML is a superset of JSON, so the same decoder reads both formats
+	var raw map[string]string
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse overrides file %s: %w", path, err)
+	}
+
+	o := &Overrides{Path: path, entries: make(map[string]Override, len(raw))}
+	for key, value := range raw {
+		key = strings.TrimSpace(key)
+		// ISRCs are case-insensitive, Spotify IDs are not
+		if isrcRegex.MatchString(key) {
+			key = strings.ToUpper(key)
+		}
+		ov, err := parseValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid override for %s in %s: %w", key, path, err)
+		}
+		ov.Key = key
+		o.entries[key] = ov
+	}
+	return o, nil
+}
+
+// Lookup returns the override for track, searching by Spotify track ID first and then by ISRC.
+func (o *Overrides) Lookup(track spotify.FullTrack) (Override, bool) {
+	if o == nil {
+		return Override{}, false
+	}
+	if ov, ok := o.entries[string(track.ID)]; ok && track.ID != "" {
+		return ov, true
+	}
+	if isrc := utils.TrackISRC(track); isrc != "" {
+		if ov, ok := o.entries[strings.ToUpper(isrc)]; ok {
+			return ov, true
+		}
+	}
+	return Override{}, false
+}
+
+func (o *Overrides) Len() int {
+	if o == nil {
+		return 0
+	}
+	return len(o.entries)
+}
+
+// parseValue accepts "skip", a bare video ID or a YouTube URL.
+func parseValue(value string) (Override, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return Override{}, errors.New("empty value")
+	}
+	if strings.EqualFold(value, Skip) {
+		return Override{Skip: true}, nil
+	}
+	if !strings.Contains(value, "/") {
+		return Override{VideoID: value}, nil
+	}
+
+	u, err := url.Parse(value)
+	if err != nil {
+		return Override{}, fmt.Errorf("invalid URL: %s", value)
+	}
+	if v := u.Query().Get("v"); v != "" {
+		return Override{VideoID: v}, nil
+	}
+	// https://youtu.be/<id>
+	if id := strings.Trim(u.Path, "/"); strings.HasSuffix(u.Host, "youtu.be") && id != "" {
+		return Override{VideoID: id}, nil
+	}
+	return Override{}, fmt.Errorf("cannot find a video ID in %s", value)
+}
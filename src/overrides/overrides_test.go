@@ -0,0 +1,117 @@
+package overrides
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+func TestParseValue(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		wantID   string
+		wantSkip bool
+		wantErr  bool
+	}{
+		{name: "skip", value: "skip", wantSkip: true},
+		{name: "skip in capitals", value: " SKIP ", wantSkip: true},
+		{name: "bare video ID", value: "dQw4w9WgXcQ", wantID: "dQw4w9WgXcQ"},
+		{name: "watch URL", value: "https://www.youtube.com/watch?v=dQw4w9WgXcQ", wantID: "dQw4w9WgXcQ"},
+		{name: "watch URL with more parameters", value: "https://www.youtube.com/watch?list=PL123&v=dQw4w9WgXcQ&t=42s", wantID: "dQw4w9WgXcQ"},
+		{name: "YouTube Music URL", value: "https://music.youtube.com/watch?v=dQw4w9WgXcQ&feature=share", wantID: "dQw4w9WgXcQ"},
+		{name: "short URL", value: "https://youtu.be/dQw4w9WgXcQ", wantID: "dQw4w9WgXcQ"},
+		{name: "short URL with a timestamp", value: "https://youtu.be/dQw4w9WgXcQ?t=42", wantID: "dQw4w9WgXcQ"},
+		{name: "empty", value: "  ", wantErr: true},
+		{name: "URL without a video", value: "https://www.youtube.com/channel/UC123", wantErr: true},
+		{name: "short URL without a video", value: "https://youtu.be/", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ov, err := parseValue(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseValue(%q) = %+v, want an error", tt.value, ov)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseValue(%q): %v", tt.value, err)
+			}
+			if ov.VideoID != tt.wantID || ov.Skip != tt.wantSkip {
+				t.Errorf("parseValue(%q) = %+v, want video %q, skip %v", tt.value, ov, tt.wantID, tt.wantSkip)
+			}
+		})
+	}
+}
+
+func TestLookup(t *testing.T) {
+	dir := t.TempDir()
+	content := `4uLU6hMCjMI75M1A2tKUQC: dQw4w9WgXcQ
+usum71703861: skip
+" GBARL9300135 ": https://youtu.be/yPYZpwSpKmA
+`
+	if err := os.WriteFile(filepath.Join(dir, "overrides.yaml"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	o, err := Load("", dir)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if o.Len() != 3 {
+		t.Errorf("Len = %d, want 3", o.Len())
+	}
+
+	track := func(id string, isrc string) spotify.FullTrack {
+		tr := spotify.FullTrack{SimpleTrack: spotify.SimpleTrack{ID: spotify.ID(id)}}
+		if isrc != "" {
+			tr.ExternalIDs = map[string]string{"isrc": isrc}
+		}
+		return tr
+	}
+	tests := []struct {
+		name     string
+		track    spotify.FullTrack
+		wantKey  string
+		wantID   string
+		wantSkip bool
+		wantOK   bool
+	}{
+		{name: "track ID", track: track("4uLU6hMCjMI75M1A2tKUQC", "GBARL9300135"), wantKey: "4uLU6hMCjMI75M1A2tKUQC", wantID: "dQw4w9WgXcQ", wantOK: true},
+		{name: "track ID is case sensitive", track: track("4ULU6HMCJMI75M1A2TKUQC", ""), wantOK: false},
+		{name: "lowercase ISRC in the file", track: track("other", "USUM71703861"), wantKey: "USUM71703861", wantSkip: true, wantOK: true},
+		{name: "lowercase ISRC of the track", track: track("", "gbarl9300135"), wantKey: "GBARL9300135", wantID: "yPYZpwSpKmA", wantOK: true},
+		{name: "no override", track: track("other", "USAAA0000001"), wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ov, ok := o.Lookup(tt.track)
+			if ok != tt.wantOK {
+				t.Fatalf("Lookup found = %v, want %v", ok, tt.wantOK)
+			}
+			if ov.Key != tt.wantKey || ov.VideoID != tt.wantID || ov.Skip != tt.wantSkip {
+				t.Errorf("Lookup = %+v, want key %q, video %q, skip %v", ov, tt.wantKey, tt.wantID, tt.wantSkip)
+			}
+		})
+	}
+
+	var none *Overrides
+	if _, ok := none.Lookup(track("4uLU6hMCjMI75M1A2tKUQC", "")); ok || none.Len() != 0 {
+		t.Error("nil overrides found an override")
+	}
+}
+
+func TestLoadInvalidValue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "overrides.json")
+	if err := os.WriteFile(path, []byte(`{"4uLU6hMCjMI75M1A2tKUQC": "https://www.youtube.com/channel/UC123"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path, ""); err == nil || !strings.Contains(err.Error(), "invalid override for 4uLU6hMCjMI75M1A2tKUQC") {
+		t.Errorf("Load error = %v, want the invalid override", err)
+	}
+}
@@ -1,10 +1,16 @@
 package parser
 
 import (
+	"bufio"
 	"errors"
 	"fmt"
+	"html"
+	"io"
+	"net/http"
 	"net/url"
+	"regexp"
 	"strings"
+	"time"
 )
 
 type SpotifyURLType int
@@ -14,23 +20,70 @@ const (
 	TrackURL
 	AlbumURL
 	PlaylistURL
+	ArtistURL
 )
 
+// httpClient resolves the short links. Tests can point it to a local server.
+var httpClient = &http.Client{Timeout: 15 * time.Second}
+
+// shortLinkHosts are the hosts of the links shared by the Spotify mobile app.
+var shortLinkHosts = map[string]bool{
+	"spotify.link":     true,
+	"spotify.app.link": true,
+}
+
+var localePrefixRegex = regexp.MustCompile(`^intl-[a-z]{2}(-[a-z]{2})?$`)
+var openSpotifyURLRegex = regexp.MustCompile(`https://open\.spotify\.com/[^"'\s<>]+`)
+
 // ParseSpotifyURL checks whether the provided string is a valid Spotify URL
 // and extracts both the resource type and ID from it.
+//
+// Supported forms:
+//   - https://open.spotify.com/<type>/<id>, with an optional locale prefix (/intl-it/...)
+//   - spotify:<type>:<id> URIs
+//   - spotify.link and spotify.app.link short links, resolved through their HTTP redirects
 func ParseSpotifyURL(spotifyURL string) (SpotifyURLType, string, error) {
+	spotifyURL = strings.TrimSpace(spotifyURL)
+	if strings.HasPrefix(spotifyURL, "spotify:") {
+		return parseSpotifyURI(spotifyURL)
+	}
+
 	parsed, err := url.Parse(spotifyURL)
 	if err != nil || parsed.Host == "" {
 		return UnknownURL, "", fmt.Errorf("invalid URL: %s", spotifyURL)
 	}
 
-	// Example of path: /track/XYZ or /playlist/XYZ
+	if shortLinkHosts[strings.ToLower(parsed.Host)] {
+		resolved, err := ResolveShortLink(spotifyURL)
+		if err != nil {
+			return UnknownURL, "", err
+		}
+		return parseOpenURL(resolved)
+	}
+
+	return parseOpenURL(parsed)
+}
+
+// parseOpenURL extracts type and ID from an open.spotify.com URL.
+func parseOpenURL(parsed *url.URL) (SpotifyURLType, string, error) {
+	// Example of path: /track/XYZ, /intl-it/track/XYZ or /embed/playlist/XYZ
 	splitPath := strings.Split(strings.Trim(parsed.Path, "/"), "/")
+	if len(splitPath) > 0 && localePrefixRegex.MatchString(strings.ToLower(splitPath[0])) {
+		splitPath = splitPath[1:]
+	}
+	if len(splitPath) > 0 && splitPath[0] == "embed" {
+		splitPath = splitPath[1:]
+	}
+	// Legacy playlist links: /user/<user>/playlist/XYZ
+	if len(splitPath) == 4 && splitPath[0] == "user" {
+		splitPath = splitPath[2:]
+	}
+
 	if len(splitPath) < 2 {
 		return UnknownURL, "", errors.New("URL path is too short")
 	}
 
-	resourceType := splitPath[0] // track, album, playlist
+	resourceType := splitPath[0] // track, album, playlist, artist
 	spotifyID := splitPath[1]
 
 	// Strip query parameters if present
@@ -38,6 +91,24 @@ func ParseSpotifyURL(spotifyURL string) (SpotifyURLType, string, error) {
 		spotifyID = spotifyID[:idx]
 	}
 
+	return resourceURLType(resourceType, spotifyID)
+}
+
+// parseSpotifyURI extracts type and ID from URIs like spotify:track:XYZ
+// or the legacy spotify:user:<user>:playlist:XYZ.
+func parseSpotifyURI(uri string) (SpotifyURLType, string, error) {
+	parts := strings.Split(uri, ":")
+	if len(parts) < 3 || parts[len(parts)-1] == "" {
+		return UnknownURL, "", fmt.Errorf("invalid Spotify URI: %s", uri)
+	}
+	return resourceURLType(parts[len(parts)-2], parts[len(parts)-1])
+}
+
+func resourceURLType(resourceType string, spotifyID string) (SpotifyURLType, string, error) {
+	if spotifyID == "" {
+		return UnknownURL, "", errors.New("missing Spotify ID")
+	}
+
 	switch resourceType {
 	case "track":
 		return TrackURL, spotifyID, nil
@@ -45,7 +116,64 @@ func ParseSpotifyURL(spotifyURL string) (SpotifyURLType, string, error) {
 		return AlbumURL, spotifyID, nil
 	case "playlist":
 		return PlaylistURL, spotifyID, nil
+	case "artist":
+		return ArtistURL, spotifyID, nil
 	default:
 		return UnknownURL, "", fmt.Errorf("unsupported Spotify resource type: %s", resourceType)
 	}
 }
+
+// ResolveShortLink follows the redirects of a spotify.link / spotify.app.link short link
+// and returns the open.spotify.com URL it points to. When the last hop is an HTML page
+// instead of a redirect, the first open.spotify.com link in the page is used.
+func ResolveShortLink(shortLink string) (*url.URL, error) {
+	resp, err := httpClient.Get(shortLink)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve short link %s: %w", shortLink, err)
+	}
+	defer func(body io.ReadCloser) {
+		if cErr := body.Close(); cErr != nil {
+			// nothing to do, the body has been read
+		}
+	}(resp.Body)
+
+	final := resp.Request.URL
+	if strings.EqualFold(final.Host, "open.spotify.com") {
+		return final, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to resolve short link %s: status code %d", shortLink, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read short link page %s: %w", shortLink, err)
+	}
+
+	match := openSpotifyURLRegex.Find(body)
+	if match == nil {
+		return nil, fmt.Errorf("short link %s does not point to open.spotify.com", shortLink)
+	}
+	return url.Parse(html.UnescapeString(string(match)))
+}
+
+// ReadURLList reads one URL per line. Empty lines and comments, from a "#" at the start of
+// a line or after a space, are ignored.
+func ReadURLList(r io.Reader) ([]string, error) {
+	var urls []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i == 0 || i > 0 && (line[i-1] == ' ' || line[i-1] == '\t') {
+			line = line[:i]
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			urls = append(urls, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read URL list: %w", err)
+	}
+	return urls, nil
+}
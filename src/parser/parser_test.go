@@ -0,0 +1,161 @@
+package parser
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestParseSpotifyURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		wantType SpotifyURLType
+		wantID   string
+		wantErr  bool
+	}{
+		{"track", "https://open.spotify.com/track/4uLU6hMCjMI75M1A2tKUQC", TrackURL, "4uLU6hMCjMI75M1A2tKUQC", false},
+		{"track with query", "https://open.spotify.com/track/4uLU6hMCjMI75M1A2tKUQC?si=abc123", TrackURL, "4uLU6hMCjMI75M1A2tKUQC", false},
+		{"album", "https://open.spotify.com/album/1DFixLWuPkv3KT3TnV35m3", AlbumURL, "1DFixLWuPkv3KT3TnV35m3", false},
+		{"playlist", "https://open.spotify.com/playlist/37i9dQZF1DXcBWIGoYBM5M", PlaylistURL, "37i9dQZF1DXcBWIGoYBM5M", false},
+		{"artist", "https://open.spotify.com/artist/0gxyHStUsqpMadRV0Di1Qt", ArtistURL, "0gxyHStUsqpMadRV0Di1Qt", false},
+		{"surrounding spaces", "  https://open.spotify.com/track/abc  ", TrackURL, "abc", false},
+		{"intl locale", "https://open.spotify.com/intl-it/track/abc", TrackURL, "abc", false},
+		{"intl locale with region", "https://open.spotify.com/intl-pt-br/album/abc", AlbumURL, "abc", false},
+		{"intl locale upper case", "https://open.spotify.com/intl-DE/playlist/abc", PlaylistURL, "abc", false},
+		{"embed", "https://open.spotify.com/embed/playlist/abc", PlaylistURL, "abc", false},
+		{"intl embed", "https://open.spotify.com/intl-fr/embed/track/abc", TrackURL, "abc", false},
+		{"legacy user playlist", "https://open.spotify.com/user/spotify/playlist/abc", PlaylistURL, "abc", false},
+		{"track URI", "spotify:track:abc", TrackURL, "abc", false},
+		{"album URI", "spotify:album:abc", AlbumURL, "abc", false},
+		{"playlist URI", "spotify:playlist:abc", PlaylistURL, "abc", false},
+		{"artist URI", "spotify:artist:abc", ArtistURL, "abc", false},
+		{"legacy user playlist URI", "spotify:user:someone:playlist:abc", PlaylistURL, "abc", false},
+		{"URI without ID", "spotify:track:", UnknownURL, "", true},
+		{"URI too short", "spotify:track", UnknownURL, "", true},
+		{"URI of an unsupported type", "spotify:show:abc", UnknownURL, "", true},
+		{"unsupported type", "https://open.spotify.com/episode/abc", UnknownURL, "", true},
+		{"path too short", "https://open.spotify.com/track", UnknownURL, "", true},
+		{"locale only", "https://open.spotify.com/intl-it/", UnknownURL, "", true},
+		{"not a URL", "not a url", UnknownURL, "", true},
+		{"empty", "", UnknownURL, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotType, gotID, err := ParseSpotifyURL(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSpotifyURL(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if gotType != tt.wantType || gotID != tt.wantID {
+				t.Errorf("ParseSpotifyURL(%q) = %v, %q, want %v, %q", tt.input, gotType, gotID, tt.wantType, tt.wantID)
+			}
+		})
+	}
+}
+
+// redirectTransport sends every request to a local server, whatever its host: the server
+// tells the hosts apart by the Host header.
+type redirectTransport struct {
+	server *httptest.Server
+}
+
+func (rt redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	target, err := url.Parse(rt.server.URL)
+	if err != nil {
+		return nil, err
+	}
+	local := req.Clone(req.Context())
+	local.URL.Scheme = target.Scheme
+	local.URL.Host = target.Host
+	local.Host = req.URL.Host
+
+	resp, err := http.DefaultTransport.RoundTrip(local)
+	if err != nil {
+		return nil, err
+	}
+	resp.Request = req
+	return resp, nil
+}
+
+// useShortLinkServer points httpClient to a server playing spotify.link, spotify.app.link
+// and open.spotify.com, restoring it at the end of the test.
+func useShortLinkServer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := strings.ToLower(r.Host)
+		switch {
+		case host == "open.spotify.com":
+			fmt.Fprint(w, "<html>player</html>")
+		case host == "spotify.link" && r.URL.Path == "/track":
+			http.Redirect(w, r, "https://spotify.app.link/track", http.StatusFound)
+		case host == "spotify.app.link" && r.URL.Path == "/track":
+			http.Redirect(w, r, "https://open.spotify.com/track/4uLU6hMCjMI75M1A2tKUQC?si=share", http.StatusMovedPermanently)
+		case host == "spotify.link" && r.URL.Path == "/page":
+			// The app link service sometimes answers with a page linking the content
+			fmt.Fprint(w, `<html><a href="https://open.spotify.com/playlist/37i9dQZF1DXcBWIGoYBM5M?si=a&amp;pi=b">Open</a></html>`)
+		case host == "spotify.link" && r.URL.Path == "/elsewhere":
+			fmt.Fprint(w, "<html>nothing here</html>")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	previous := httpClient
+	httpClient = &http.Client{Transport: redirectTransport{server: server}}
+	t.Cleanup(func() { httpClient = previous })
+}
+
+func TestParseSpotifyURLShortLinks(t *testing.T) {
+	useShortLinkServer(t)
+
+	tests := []struct {
+		name     string
+		input    string
+		wantType SpotifyURLType
+		wantID   string
+		wantErr  bool
+	}{
+		{"redirects", "https://spotify.link/track", TrackURL, "4uLU6hMCjMI75M1A2tKUQC", false},
+		{"app link redirect", "https://spotify.app.link/track", TrackURL, "4uLU6hMCjMI75M1A2tKUQC", false},
+		{"upper case host", "https://SPOTIFY.LINK/track", TrackURL, "4uLU6hMCjMI75M1A2tKUQC", false},
+		{"HTML page", "https://spotify.link/page", PlaylistURL, "37i9dQZF1DXcBWIGoYBM5M", false},
+		{"page without a Spotify link", "https://spotify.link/elsewhere", UnknownURL, "", true},
+		{"not found", "https://spotify.link/missing", UnknownURL, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotType, gotID, err := ParseSpotifyURL(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSpotifyURL(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if gotType != tt.wantType || gotID != tt.wantID {
+				t.Errorf("ParseSpotifyURL(%q) = %v, %q, want %v, %q", tt.input, gotType, gotID, tt.wantType, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestReadURLList(t *testing.T) {
+	input := strings.Join([]string{
+		"# my playlists",
+		"https://open.spotify.com/playlist/a",
+		"",
+		"   https://open.spotify.com/album/b   # the new one",
+		"spotify:track:c#not-a-comment",
+		"\t# indented comment",
+	}, "\n")
+
+	got, err := ReadURLList(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"https://open.spotify.com/playlist/a", "https://open.spotify.com/album/b", "spotify:track:c#not-a-comment"}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("ReadURLList() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,166 @@
+package playlistfile
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type Format string
+
+const (
+	M3U8 Format = "m3u8"
+	XSPF Format = "xspf"
+	PLS  Format = "pls"
+)
+
+// ParseFormats parses a comma separated list of playlist file formats.
+// "none" (or an empty list) disables the playlist files.
+func ParseFormats(input string) ([]Format, error) {
+	var formats []Format
+	seen := make(map[Format]bool)
+	for _, part := range strings.Split(strings.ToLower(input), ",") {
+		var f Format
+		switch strings.TrimSpace(part) {
+		case "", "none":
+			continue
+		case "m3u8", "m3u":
+			f = M3U8
+		case "xspf":
+			f = XSPF
+		case "pls":
+			f = PLS
+		default:
+			return nil, fmt.Errorf("invalid playlist file format: '%s' (valid: m3u8, xspf, pls, none)", part)
+		}
+		if !seen[f] {
+			seen[f] = true
+			formats = append(formats, f)
+		}
+	}
+	return formats, nil
+}
+
+// Entry is a track of the playlist file.
+type Entry struct {
+	// Path is relative to the folder of the playlist file
+	Path     string
+	Title    string
+	Artist   string
+	Album    string
+	Duration int // seconds
+}
+
+// Write saves the entries, in order, as "<dir>/<name>.<format>" for every format and
+// returns the paths of the written files.
+func Write(dir string, name string, entries []Entry, formats []Format) ([]string, error) {
+	var written []string
+	for _, f := range formats {
+		var data []byte
+		switch f {
+		case M3U8:
+			data = m3u8(name, entries)
+		case XSPF:
+			var err error
+			if data, err = xspf(name, entries); err != nil {
+				return written, err
+			}
+		case PLS:
+			data = pls(entries)
+		default:
+			return written, fmt.Errorf("unsupported playlist file format: %s", f)
+		}
+
+		path := filepath.Join(dir, name+"."+string(f))
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return written, fmt.Errorf("failed to write playlist file: %w", err)
+		}
+		written = append(written, path)
+	}
+	return written, nil
+}
+
+func displayTitle(e Entry) string {
+	if e.Artist == "" {
+		return e.Title
+	}
+	return e.Artist + " - " + e.Title
+}
+
+func m3u8(name string, entries []Entry) []byte {
+	var b bytes.Buffer
+	b.WriteString("#EXTM3U\n")
+	b.WriteString("#PLAYLIST:" + oneLine(name) + "\n")
+	for _, e := range entries {
+		fmt.Fprintf(&b, "#EXTINF:%d,%s\n", e.Duration, oneLine(displayTitle(e)))
+		b.WriteString(filepath.ToSlash(e.Path) + "\n")
+	}
+	return b.Bytes()
+}
+
+func pls(entries []Entry) []byte {
+	var b bytes.Buffer
+	b.WriteString("[playlist]\n")
+	for i, e := range entries {
+		n := i + 1
+		fmt.Fprintf(&b, "File%d=%s\n", n, filepath.ToSlash(e.Path))
+		fmt.Fprintf(&b, "Title%d=%s\n", n, oneLine(displayTitle(e)))
+		fmt.Fprintf(&b, "Length%d=%d\n", n, e.Duration)
+	}
+	fmt.Fprintf(&b, "NumberOfEntries=%d\n", len(entries))
+	b.WriteString("Version=2\n")
+	return b.Bytes()
+}
+
+type xspfPlaylist struct {
+	XMLName xml.Name    `xml:"playlist"`
+	Version string      `xml:"version,attr"`
+	XMLNS   string      `xml:"xmlns,attr"`
+	Title   string      `xml:"title"`
+	Tracks  []xspfTrack `xml:"trackList>track"`
+}
+
+type xspfTrack struct {
+	Location string `xml:"location"`
+	Title    string `xml:"title,omitempty"`
+	Creator  string `xml:"creator,omitempty"`
+	Album    string `xml:"album,omitempty"`
+	Duration int    `xml:"duration,omitempty"` // milliseconds
+}
+
+func xspf(name string, entries []Entry) ([]byte, error) {
+	playlist := xspfPlaylist{Version: "1", XMLNS: "http://xspf.org/ns/0/", Title: name}
+	for _, e := range entries {
+		playlist.Tracks = append(playlist.Tracks, xspfTrack{
+			Location: relativeURI(e.Path),
+			Title:    e.Title,
+			Creator:  e.Artist,
+			Album:    e.Album,
+			Duration: e.Duration * 1000,
+		})
+	}
+
+	data, err := xml.MarshalIndent(playlist, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode XSPF playlist: %w", err)
+	}
+	return append([]byte(xml.Header), append(data, '\n')...), nil
+}
+
+// relativeURI escapes every segment of path: XSPF locations are URIs, not file paths.
+func relativeURI(path string) string {
+	segments := strings.Split(filepath.ToSlash(path), "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.Join(segments, "/")
+}
+
+// oneLine keeps a title from breaking the line based formats.
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,266 @@
+package progress
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Phase string
+
+const (
+	PhaseIdle        Phase = ""
+	PhaseSearching   Phase = "searching"
+	PhaseDownloading Phase = "downloading"
+	PhaseTagging     Phase = "tagging"
+)
+
+// redrawInterval is how often the terminal view is refreshed.
+const redrawInterval = 250 * time.Millisecond
+
+// maxTrackWidth keeps the worker lines on a single terminal line.
+const maxTrackWidth = 50
+
+// IsTerminal reports whether f is an interactive terminal.
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// Progress shows how a download is going: overall counts with ETA and one line per worker
+// with its current track and phase. On a terminal the view is redrawn in place; otherwise
+// every change is printed as a plain line. A nil *Progress shows nothing.
+type Progress struct {
+	out io.Writer
+	tty bool
+
+	mu      sync.Mutex
+	total   int
+	done    int
+	failed  int
+	start   time.Time
+	workers []workerState
+	// lines is how many lines of the terminal view are on screen
+	lines int
+	// running is true between Start and Stop, while the view is drawn
+	running bool
+
+	stop     chan struct{}
+	stopped  sync.WaitGroup
+	prevLog  io.Writer
+	captured bool
+}
+
+type workerState struct {
+	track   string
+	phase   Phase
+	percent float64
+}
+
+// Worker reports the status of a single worker.
+type Worker struct {
+	p  *Progress
+	id int
+}
+
+// New returns the progress of total tracks processed by workers workers. With tty the view
+// is redrawn in place, so out must be a terminal.
+func New(out io.Writer, tty bool, total int, workers int) *Progress {
+	return &Progress{
+		out:     out,
+		tty:     tty,
+		total:   total,
+		start:   time.Now(),
+		workers: make([]workerState, workers),
+	}
+}
+
+// Start begins redrawing the terminal view and routes the log package through it,
+// so log lines are printed above the view instead of over it.
+func (p *Progress) Start() {
+	if p == nil || !p.tty {
+		return
+	}
+
+	p.mu.Lock()
+	p.running = true
+	p.mu.Unlock()
+
+	p.prevLog = log.Writer()
+	log.SetOutput(p)
+	p.captured = true
+
+	p.stop = make(chan struct{})
+	p.stopped.Add(1)
+	go func() {
+		defer p.stopped.Done()
+		ticker := time.NewTicker(redrawInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.stop:
+				return
+			case <-ticker.C:
+				p.mu.Lock()
+				p.redrawLocked()
+				p.mu.Unlock()
+			}
+		}
+	}()
+}
+
+// Stop removes the terminal view, leaving only the overall counts, and restores the log output.
+func (p *Progress) Stop() {
+	if p == nil {
+		return
+	}
+	if p.stop != nil {
+		close(p.stop)
+		p.stopped.Wait()
+		p.stop = nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.running = false
+	if p.captured {
+		log.SetOutput(p.prevLog)
+		p.captured = false
+	}
+	if p.tty {
+		p.clearLocked()
+		fmt.Fprintln(p.out, p.summaryLocked())
+	}
+}
+
+// Write prints log output above the terminal view.
+func (p *Progress) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.clearLocked()
+	n, err := p.out.Write(b)
+	p.redrawLocked()
+	return n, err
+}
+
+// Worker returns the reporter of worker id (0-based).
+func (p *Progress) Worker(id int) *Worker {
+	if p == nil {
+		return nil
+	}
+	return &Worker{p: p, id: id}
+}
+
+// TrackDone counts a processed track, successful or not.
+func (p *Progress) TrackDone(ok bool) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if ok {
+		p.done++
+	} else {
+		p.failed++
+	}
+	if !p.tty {
+		fmt.Fprintln(p.out, p.summaryLocked())
+	}
+}
+
+// Phase sets the track the worker is on and what it is doing with it.
+func (w *Worker) Phase(track string, phase Phase) {
+	if w == nil {
+		return
+	}
+	w.p.mu.Lock()
+	defer w.p.mu.Unlock()
+	w.p.workers[w.id] = workerState{track: track, phase: phase}
+	if !w.p.tty && phase != PhaseIdle {
+		fmt.Fprintf(w.p.out, "[worker %d] %s %s\n", w.id+1, phase, track)
+	}
+}
+
+// Percent sets how much of the current phase is done, from 0 to 100.
+// Plain output skips it: a line per percent would bury everything else.
+func (w *Worker) Percent(percent float64) {
+	if w == nil {
+		return
+	}
+	w.p.mu.Lock()
+	defer w.p.mu.Unlock()
+	w.p.workers[w.id].percent = percent
+}
+
+// Idle marks the worker as waiting for the next track.
+func (w *Worker) Idle() {
+	w.Phase("", PhaseIdle)
+}
+
+func (p *Progress) summaryLocked() string {
+	processed := p.done + p.failed
+	remaining := p.total - processed
+	summary := fmt.Sprintf("[%d/%d] %d done, %d failed, %d remaining", processed, p.total, p.done, p.failed, remaining)
+	if processed > 0 && remaining > 0 {
+		elapsed := time.Since(p.start)
+		eta := time.Duration(float64(elapsed) / float64(processed) * float64(remaining))
+		summary += ", ETA " + eta.Round(time.Second).String()
+	}
+	return summary
+}
+
+func (p *Progress) clearLocked() {
+	if p.lines == 0 {
+		return
+	}
+	// Back to the first line of the view, then erase to the end of the screen
+	fmt.Fprintf(p.out, "\x1b[%dA\x1b[J", p.lines)
+	p.lines = 0
+}
+
+func (p *Progress) redrawLocked() {
+	if !p.running {
+		return
+	}
+	p.clearLocked()
+
+	lines := []string{bar(p.done+p.failed, p.total) + " " + p.summaryLocked()}
+	for i, w := range p.workers {
+		status := "idle"
+		if w.phase != PhaseIdle {
+			status = string(w.phase)
+			if w.phase == PhaseDownloading && w.percent > 0 {
+				status += fmt.Sprintf(" %5.1f%%", w.percent)
+			}
+			status = fmt.Sprintf("%-18s %s", status, truncate(w.track, maxTrackWidth))
+		}
+		lines = append(lines, fmt.Sprintf("  worker %d: %s", i+1, status))
+	}
+
+	fmt.Fprint(p.out, strings.Join(lines, "\n")+"\n")
+	p.lines = len(lines)
+}
+
+func bar(processed int, total int) string {
+	const width = 20
+	filled := width
+	if total > 0 {
+		filled = processed * width / total
+	}
+	return "[" + strings.Repeat("=", filled) + strings.Repeat(" ", width-filled) + "]"
+}
+
+func truncate(s string, max int) string {
+	r := []rune(s)
+	if len(r) <= max {
+		return s
+	}
+	return string(r[:max-1]) + "…"
+}
@@ -0,0 +1,160 @@
+package report
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Candidate is a YouTube video considered for a track, with the reason it was picked or rejected.
+type Candidate struct {
+	VideoID  string  `json:"video_id"`
+	Title    string  `json:"title"`
+	Channel  string  `json:"channel"`
+	URL      string  `json:"url"`
+	Duration string  `json:"duration,omitempty"`
+	Score    float64 `json:"score"`
+	Picked   bool    `json:"picked"`
+	Reason   string  `json:"reason"`
+}
+
+// Entry is the match resolution of a single Spotify track.
+type Entry struct {
+	Index      int         `json:"index"`
+	TrackID    string      `json:"track_id"`
+	Artist     string      `json:"artist"`
+	Title      string      `json:"title"`
+	Duration   string      `json:"duration"`
+	Query      string      `json:"query,omitempty"`
+	Note       string      `json:"note,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	Candidates []Candidate `json:"candidates,omitempty"`
+}
+
+// Report collects the entries written by the download workers. It is safe for concurrent use.
+type Report struct {
+	mu      sync.Mutex
+	entries []Entry
+}
+
+func New() *Report {
+	return &Report{}
+}
+
+func (r *Report) Add(e Entry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, e)
+}
+
+// Entries returns the entries in track order.
+func (r *Report) Entries() []Entry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entries := make([]Entry, len(r.entries))
+	copy(entries, r.entries)
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Index < entries[j].Index
+	})
+	return entries
+}
+
+// WriteFile writes the report as JSON or CSV, depending on the extension of path.
+func (r *Report) WriteFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create report file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = r.WriteJSON(f)
+	case ".csv":
+		err = r.WriteCSV(f)
+	default:
+		err = r.Print(f)
+	}
+
+	if cErr := f.Close(); err == nil {
+		err = cErr
+	}
+	return err
+}
+
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r.Entries())
+}
+
+// WriteCSV writes one row per candidate. Tracks without candidates get a single row.
+func (r *Report) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	header := []string{
+		"index", "track_id", "artist", "title", "duration", "query", "note", "error",
+		"rank", "video_id", "video_title", "channel", "video_duration", "score", "picked", "reason",
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	for _, e := range r.Entries() {
+		base := []string{
+			strconv.Itoa(e.Index + 1), e.TrackID, e.Artist, e.Title, e.Duration, e.Query, e.Note, e.Error,
+		}
+		if len(e.Candidates) == 0 {
+			if err := cw.Write(append(base, "", "", "", "", "", "", "", "")); err != nil {
+				return err
+			}
+			continue
+		}
+		for i, c := range e.Candidates {
+			row := append(append([]string{}, base...),
+				strconv.Itoa(i+1), c.VideoID, c.Title, c.Channel, c.Duration,
+				strconv.FormatFloat(c.Score, 'f', 1, 64), strconv.FormatBool(c.Picked), c.Reason,
+			)
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// Print writes the report in a human-readable form.
+func (r *Report) Print(w io.Writer) error {
+	for _, e := range r.Entries() {
+		if _, err := fmt.Fprintf(w, "[%d] %s - %s (%s)\n", e.Index+1, e.Artist, e.Title, e.Duration); err != nil {
+			return err
+		}
+		if e.Query != "" {
+			fmt.Fprintf(w, "    query: %s\n", e.Query)
+		}
+		if e.Note != "" {
+			fmt.Fprintf(w, "    %s\n", e.Note)
+		}
+		if e.Error != "" {
+			fmt.Fprintf(w, "    error: %s\n", e.Error)
+		}
+		for _, c := range e.Candidates {
+			marker := " "
+			if c.Picked {
+				marker = "*"
+			}
+			fmt.Fprintf(w, "  %s %6.1f  %8s  %s [%s] %s\n", marker, c.Score, c.Duration, c.Title, c.Channel, c.URL)
+			fmt.Fprintf(w, "              %s\n", c.Reason)
+		}
+		fmt.Fprintln(w)
+	}
+	return nil
+}
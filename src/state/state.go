@@ -0,0 +1,165 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileName is the name of the state file kept in every output directory.
+const FileName = ".playlist-download.json"
+
+type Status string
+
+const (
+	StatusPending Status = "pending"
+	StatusDone    Status = "done"
+	StatusFailed  Status = "failed"
+)
+
+// Entry records what happened to a single Spotify track in a previous run.
+type Entry struct {
+	TrackID   string    `json:"track_id"`
+	VideoID   string    `json:"video_id,omitempty"`
+	Path      string    `json:"path,omitempty"`
+	Status    Status    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Store is the persistent per-output-directory download state.
+// It is safe for concurrent use by the download workers.
+type Store struct {
+	path    string
+	mu      sync.Mutex
+	Entries map[string]*Entry `json:"entries"`
+	// Playlists maps the ID of a synced playlist to the tracks it had at the last sync, so
+	// a sync only prunes its own tracks from a folder shared with other downloads
+	Playlists map[string][]string `json:"playlists,omitempty"`
+}
+
+// Load reads the state file from outputDir. A missing file yields an empty store.
+func Load(outputDir string) (*Store, error) {
+	s := &Store{
+		path:    filepath.Join(outputDir, FileName),
+		Entries: make(map[string]*Entry),
+	}
+
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file %s: %w", s.path, err)
+	}
+
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("failed to parse state file %s: %w", s.path, err)
+	}
+	if s.Entries == nil {
+		s.Entries = make(map[string]*Entry)
+	}
+	return s, nil
+}
+
+// Get returns a copy of the entry for trackID, if any.
+func (s *Store) Get(trackID string) (Entry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.Entries[trackID]
+	if !ok {
+		return Entry{}, false
+	}
+	return *e, true
+}
+
+// IsDone reports whether trackID was downloaded before and its file is still on disk.
+func (s *Store) IsDone(trackID string) bool {
+	e, ok := s.Get(trackID)
+	if !ok || e.Status != StatusDone || e.Path == "" {
+		return false
+	}
+	_, err := os.Stat(e.Path)
+	return err == nil
+}
+
+// Set stores the entry and writes the state file, so an interrupted run can be resumed.
+func (s *Store) Set(e Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e.UpdatedAt = time.Now()
+	s.Entries[e.TrackID] = &e
+	return s.saveLocked()
+}
+
+// PlaylistTracks returns the tracks recorded for playlistID by the last sync.
+func (s *Store) PlaylistTracks(playlistID string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.Playlists[playlistID]...)
+}
+
+// SetPlaylistTracks records the tracks of playlistID and writes the state file.
+func (s *Store) SetPlaylistTracks(playlistID string, trackIDs []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Playlists == nil {
+		s.Playlists = make(map[string][]string)
+	}
+	s.Playlists[playlistID] = trackIDs
+	return s.saveLocked()
+}
+
+// Remove drops the entry for trackID and writes the state file.
+func (s *Store) Remove(trackID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.Entries, trackID)
+	return s.saveLocked()
+}
+
+// All returns a snapshot of every entry in the store.
+func (s *Store) All() []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := make([]Entry, 0, len(s.Entries))
+	for _, e := range s.Entries {
+		entries = append(entries, *e)
+	}
+	return entries
+}
+
+// Save writes the state file.
+func (s *Store) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.saveLocked()
+}
+
+func (s *Store) saveLocked() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+
+	// Write to a temp file first so a crash never leaves a truncated state file behind
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to replace state file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadMissingFile(t *testing.T) {
+	s, err := Load(t.TempDir())
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(s.All()) != 0 || len(s.PlaylistTracks("pl")) != 0 {
+		t.Errorf("got entries %v and playlist tracks %v, want an empty store", s.All(), s.PlaylistTracks("pl"))
+	}
+}
+
+func TestLoadCorruptFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, FileName), []byte(`{"entries": {`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(dir); err == nil {
+		t.Fatal("Load succeeded on a truncated state file")
+	}
+}
+
+func TestSaveLoadRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	track := filepath.Join(dir, "Song.mp3")
+	if err := os.WriteFile(track, []byte("audio"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	entries := []Entry{
+		{TrackID: "done", VideoID: "vid1", Path: track, Status: StatusDone},
+		{TrackID: "failed", Status: StatusFailed, Error: "no match"},
+		{TrackID: "removed", Status: StatusDone, Path: filepath.Join(dir, "Gone.mp3")},
+	}
+	for _, e := range entries {
+		if err := s.Set(e); err != nil {
+			t.Fatalf("Set %s: %v", e.TrackID, err)
+		}
+	}
+	if err := s.Remove("removed"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if err := s.SetPlaylistTracks("pl", []string{"done", "failed"}); err != nil {
+		t.Fatalf("SetPlaylistTracks: %v", err)
+	}
+
+	loaded, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load after save: %v", err)
+	}
+	if got := len(loaded.All()); got != 2 {
+		t.Errorf("got %d entries, want 2", got)
+	}
+	for _, want := range entries[:2] {
+		got, ok := loaded.Get(want.TrackID)
+		if !ok {
+			t.Errorf("entry %s not saved", want.TrackID)
+			continue
+		}
+		if got.VideoID != want.VideoID || got.Path != want.Path || got.Status != want.Status || got.Error != want.Error {
+			t.Errorf("entry %s = %+v, want %+v", want.TrackID, got, want)
+		}
+		if got.UpdatedAt.IsZero() {
+			t.Errorf("entry %s saved without its update time", want.TrackID)
+		}
+	}
+	if _, ok := loaded.Get("removed"); ok {
+		t.Error("removed entry still in the state file")
+	}
+	if got := loaded.PlaylistTracks("pl"); !reflect.DeepEqual(got, []string{"done", "failed"}) {
+		t.Errorf("playlist tracks = %v, want [done failed]", got)
+	}
+
+	if !loaded.IsDone("done") {
+		t.Error("IsDone = false for a done track whose file exists")
+	}
+	if loaded.IsDone("failed") {
+		t.Error("IsDone = true for a failed track")
+	}
+	if err := os.Remove(track); err != nil {
+		t.Fatal(err)
+	}
+	if loaded.IsDone("done") {
+		t.Error("IsDone = true for a done track whose file was deleted")
+	}
+}
+
+func TestSaveFailureLeavesNoPartialFile(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	// A non-empty directory in place of the state file makes the final rename fail
+	if err := os.MkdirAll(filepath.Join(dir, FileName, "blocker"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Set(Entry{TrackID: "id", Status: StatusDone}); err == nil {
+		t.Fatal("Set succeeded although the state file cannot be replaced")
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		if f.Name() != FileName {
+			t.Errorf("file %s left behind after a failed save", f.Name())
+		}
+	}
+}
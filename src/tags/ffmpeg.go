@@ -0,0 +1,198 @@
+package tags
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"playlist-download/src/lyrics"
+	"playlist-download/src/utils"
+	"strings"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+// field is a single metadata key/value, named with the ffmpeg generic keys
+// (title, artist, album, date, ...). ffmpeg turns them into Vorbis comments for
+// ogg/opus/flac and into the matching MP4 atoms (©nam, ©ART, ©alb, ©day, ...) for m4a.
+type field struct {
+	key   string
+	value string
+}
+
+func metadataFields(trackData spotify.FullTrack, sourceURL string, trackLyrics *lyrics.Lyrics) []field {
+	fields := []field{
+		{"title", trackData.Name},
+		{"artist", joinArtists(trackData.Artists)},
+		{"album", trackData.Album.Name},
+		{"album_artist", albumArtist(trackData)},
+		{"track", trackNumber(trackData)},
+		{"disc", discNumber(trackData)},
+		{"date", releaseDate(trackData)},
+		{"ISRC", utils.TrackISRC(trackData)},
+		{"lyrics", lyricsText(trackLyrics)},
+	}
+	fields = append(fields, identifierFields(trackData, sourceURL)...)
+
+	var nonEmpty []field
+	for _, f := range fields {
+		if f.value != "" {
+			nonEmpty = append(nonEmpty, f)
+		}
+	}
+	return nonEmpty
+}
+
+// tagVorbisFile writes Vorbis comments to an ogg, opus or flac file. The cover goes into
+// a METADATA_BLOCK_PICTURE: a native block for flac, a base64 comment for ogg and opus.
+func tagVorbisFile(ctx context.Context, fileName string, trackData spotify.FullTrack, coverArt []byte, sourceURL string, trackLyrics *lyrics.Lyrics) error {
+	fields := metadataFields(trackData, sourceURL, trackLyrics)
+	isFLAC := strings.EqualFold(filepath.Ext(fileName), ".flac")
+
+	if len(coverArt) > 0 && !isFLAC {
+		fields = append(fields, field{"METADATA_BLOCK_PICTURE", base64.StdEncoding.EncodeToString(pictureBlock(coverArt))})
+	}
+
+	var streamCover []byte
+	if isFLAC {
+		streamCover = coverArt
+	}
+	return rewriteWithFFmpeg(ctx, fileName, fields, streamCover)
+}
+
+// tagMP4File writes the MP4 metadata atoms of an m4a file, with the cover as covr atom.
+func tagMP4File(ctx context.Context, fileName string, trackData spotify.FullTrack, coverArt []byte, sourceURL string, trackLyrics *lyrics.Lyrics) error {
+	return rewriteWithFFmpeg(ctx, fileName, metadataFields(trackData, sourceURL, trackLyrics), coverArt, "-movflags", "+use_metadata_tags")
+}
+
+// rewriteWithFFmpeg copies the audio stream of fileName into a new file with the given
+// metadata (and cover as attached picture, if any) and replaces the original with it.
+// The metadata goes through an ffmetadata file, so long values don't hit the command line limits.
+func rewriteWithFFmpeg(ctx context.Context, fileName string, fields []field, cover []byte, extraArgs ...string) error {
+	dir := filepath.Dir(fileName)
+
+	metaFile, err := os.CreateTemp(dir, ".tags-*.txt")
+	if err != nil {
+		return fmt.Errorf("failed to create metadata file: %w", err)
+	}
+	defer removeTemp(metaFile.Name())
+
+	var meta strings.Builder
+	meta.WriteString(";FFMETADATA1\n")
+	for _, f := range fields {
+		meta.WriteString(escapeFFMetadata(f.key) + "=" + escapeFFMetadata(removeUnsupportedRunes(f.value)) + "\n")
+	}
+	if _, err := metaFile.WriteString(meta.String()); err != nil {
+		metaFile.Close()
+		return fmt.Errorf("failed to write metadata file: %w", err)
+	}
+	if err := metaFile.Close(); err != nil {
+		return fmt.Errorf("failed to write metadata file: %w", err)
+	}
+
+	args := []string{"-y", "-v", "error", "-i", fileName, "-i", metaFile.Name()}
+	maps := []string{"-map", "0:a", "-map_metadata", "1", "-map_metadata:s:a", "1:g"}
+
+	if len(cover) > 0 {
+		coverFile, err := os.CreateTemp(dir, ".cover-*"+imageExtension(cover))
+		if err != nil {
+			return fmt.Errorf("failed to create cover file: %w", err)
+		}
+		defer removeTemp(coverFile.Name())
+		if _, err := coverFile.Write(cover); err != nil {
+			coverFile.Close()
+			return fmt.Errorf("failed to write cover file: %w", err)
+		}
+		if err := coverFile.Close(); err != nil {
+			return fmt.Errorf("failed to write cover file: %w", err)
+		}
+
+		args = append(args, "-i", coverFile.Name())
+		maps = append(maps, "-map", "2:v", "-disposition:v", "attached_pic")
+	}
+
+	tmpOutput := strings.TrimSuffix(fileName, filepath.Ext(fileName)) + ".tagging" + filepath.Ext(fileName)
+	args = append(args, maps...)
+	args = append(args, "-c", "copy")
+	args = append(args, extraArgs...)
+	args = append(args, tmpOutput)
+
+	if _, err := utils.RunCmdContext(ctx, "ffmpeg", args...); err != nil {
+		removeTemp(tmpOutput)
+		return fmt.Errorf("ffmpeg failed to write tags: %w", err)
+	}
+	if err := os.Rename(tmpOutput, fileName); err != nil {
+		removeTemp(tmpOutput)
+		return fmt.Errorf("failed to replace tagged file: %w", err)
+	}
+	return nil
+}
+
+// pictureBlock builds a FLAC METADATA_BLOCK_PICTURE (front cover) for the image.
+func pictureBlock(img []byte) []byte {
+	mimeType := imageMIMEType(img)
+	description := "Front cover"
+
+	var width, height, depth uint32
+	if cfg, _, err := image.DecodeConfig(bytes.NewReader(img)); err == nil {
+		width, height, depth = uint32(cfg.Width), uint32(cfg.Height), 24
+	}
+
+	var buf bytes.Buffer
+	write := func(v uint32) {
+		_ = binary.Write(&buf, binary.BigEndian, v)
+	}
+	write(3) // front cover
+	write(uint32(len(mimeType)))
+	buf.WriteString(mimeType)
+	write(uint32(len(description)))
+	buf.WriteString(description)
+	write(width)
+	write(height)
+	write(depth)
+	write(0) // colors, only for indexed images
+	write(uint32(len(img)))
+	buf.Write(img)
+	return buf.Bytes()
+}
+
+// imageMIMEType sniffs the image format from its first bytes. Spotify serves JPEGs,
+// which is also the answer when the data isn't recognized as an image.
+func imageMIMEType(img []byte) string {
+	mimeType := http.DetectContentType(img)
+	if !strings.HasPrefix(mimeType, "image/") {
+		return "image/jpeg"
+	}
+	return mimeType
+}
+
+func imageExtension(img []byte) string {
+	switch imageMIMEType(img) {
+	case "image/png":
+		return ".png"
+	case "image/webp":
+		return ".webp"
+	default:
+		return ".jpg"
+	}
+}
+
+var ffmetadataEscaper = strings.NewReplacer(`\`, `\\`, "=", `\=`, ";", `\;`, "#", `\#`, "\n", "\\\n")
+
+func escapeFFMetadata(s string) string {
+	return ffmetadataEscaper.Replace(s)
+}
+
+func removeTemp(path string) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		log.Printf("error removing temporary file %s: %v", path, err)
+	}
+}
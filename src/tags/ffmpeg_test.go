@@ -0,0 +1,52 @@
+package tags
+
+import "testing"
+
+func TestEscapeFFMetadata(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "plain", in: "Back In Black", want: "Back In Black"},
+		{name: "equals", in: "a=b", want: `a\=b`},
+		{name: "semicolon", in: "Artist; Other", want: `Artist\; Other`},
+		{name: "hash", in: "#1 Hit", want: `\#1 Hit`},
+		{name: "backslash", in: `AC\DC`, want: `AC\\DC`},
+		{name: "newline", in: "line 1\nline 2", want: "line 1\\\nline 2"},
+		{name: "escaped backslash before a special character", in: `\=`, want: `\\\=`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := escapeFFMetadata(tt.in); got != tt.want {
+				t.Errorf("escapeFFMetadata(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImageMIMEType(t *testing.T) {
+	tests := []struct {
+		name    string
+		img     []byte
+		want    string
+		wantExt string
+	}{
+		{name: "jpeg", img: []byte("\xff\xd8\xff\xe0\x00\x10JFIF\x00"), want: "image/jpeg", wantExt: ".jpg"},
+		{name: "png", img: []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), want: "image/png", wantExt: ".png"},
+		{name: "webp", img: []byte("RIFF\x00\x00\x00\x00WEBPVP8 "), want: "image/webp", wantExt: ".webp"},
+		{name: "not an image", img: []byte("<html></html>"), want: "image/jpeg", wantExt: ".jpg"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := imageMIMEType(tt.img); got != tt.want {
+				t.Errorf("imageMIMEType = %q, want %q", got, tt.want)
+			}
+			if got := imageExtension(tt.img); got != tt.wantExt {
+				t.Errorf("imageExtension = %q, want %q", got, tt.wantExt)
+			}
+		})
+	}
+}
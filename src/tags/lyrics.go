@@ -0,0 +1,80 @@
+package tags
+
+import (
+	"encoding/binary"
+	"io"
+	"playlist-download/src/lyrics"
+	"strings"
+
+	"github.com/bogem/id3v2"
+)
+
+// lyricsLanguage is the ISO-639-2 code for "unknown": LRC sources don't say the language.
+const lyricsLanguage = "XXX"
+
+// syncedLyricsFrame is an ID3v2 SYLT frame, which the id3v2 library doesn't provide.
+// The text is always UTF-8 and the timestamps are in milliseconds.
+type syncedLyricsFrame struct {
+	lines []lyrics.Line
+}
+
+func (f syncedLyricsFrame) Size() int {
+	// encoding + language + timestamp format + content type + empty descriptor
+	size := 1 + 3 + 1 + 1 + 1
+	for _, l := range f.lines {
+		size += len(l.Text) + 1 + 4
+	}
+	return size
+}
+
+func (f syncedLyricsFrame) UniqueIdentifier() string {
+	return lyricsLanguage
+}
+
+func (f syncedLyricsFrame) WriteTo(w io.Writer) (int64, error) {
+	buf := make([]byte, 0, f.Size())
+	buf = append(buf, id3v2.EncodingUTF8.Key)
+	buf = append(buf, lyricsLanguage...)
+	buf = append(buf, 2) // absolute time in milliseconds
+	buf = append(buf, 1) // content type: lyrics
+	buf = append(buf, 0) // empty content descriptor
+	for _, l := range f.lines {
+		buf = append(buf, l.Text...)
+		buf = append(buf, 0)
+		buf = binary.BigEndian.AppendUint32(buf, uint32(l.Time.Milliseconds()))
+	}
+	n, err := w.Write(buf)
+	return int64(n), err
+}
+
+// addLyricsFrames embeds the plain lyrics as USLT and the synced ones as SYLT.
+func addLyricsFrames(mp3File *id3v2.Tag, trackLyrics *lyrics.Lyrics) {
+	mp3File.DeleteFrames("USLT")
+	mp3File.DeleteFrames("SYLT")
+	if trackLyrics == nil {
+		return
+	}
+
+	if plain := strings.TrimSpace(trackLyrics.Plain); plain != "" {
+		mp3File.AddUnsynchronisedLyricsFrame(id3v2.UnsynchronisedLyricsFrame{
+			Encoding: id3v2.EncodingUTF8,
+			Language: lyricsLanguage,
+			Lyrics:   plain,
+		})
+	}
+	if lines := trackLyrics.Lines(); len(lines) > 0 {
+		mp3File.AddFrame("SYLT", syncedLyricsFrame{lines: lines})
+	}
+}
+
+// lyricsText is the value of the lyrics field of Vorbis comments and MP4 atoms:
+// players reading synced lyrics there expect LRC, the others show it as is.
+func lyricsText(trackLyrics *lyrics.Lyrics) string {
+	if trackLyrics == nil {
+		return ""
+	}
+	if synced := strings.TrimSpace(trackLyrics.Synced); synced != "" {
+		return synced
+	}
+	return strings.TrimSpace(trackLyrics.Plain)
+}
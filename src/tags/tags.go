@@ -1,20 +1,52 @@
 package tags
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
+	"playlist-download/src/lyrics"
+	"playlist-download/src/utils"
+
 	"github.com/bogem/id3v2"
 	"github.com/zmb3/spotify/v2"
 )
 
-// TagFileWithSpotifyMetadata applies metadata (artist, album, year, cover art) to an MP3 file.
-func TagFileWithSpotifyMetadata(fileName string, trackData spotify.FullTrack, coverArt []byte) error {
+// Descriptions of the user defined (TXXX) frames, also used as Vorbis comment and MP4 keys.
+const (
+	SpotifyTrackID  = "SPOTIFY_TRACK_ID"
+	SpotifyAlbumID  = "SPOTIFY_ALBUM_ID"
+	SpotifyArtistID = "SPOTIFY_ARTIST_ID"
+	YouTubeURL      = "YOUTUBE_URL"
+)
+
+// TagFile applies the Spotify metadata to an audio file, picking the tag format from
+// the extension: ID3v2 for mp3, Vorbis comments for ogg/opus/flac, MP4 atoms for m4a.
+// sourceURL is the YouTube video the audio comes from; trackLyrics may be nil. Cancelling
+// ctx stops ffmpeg, leaving the file as it was.
+func TagFile(ctx context.Context, fileName string, trackData spotify.FullTrack, coverArt []byte, sourceURL string, trackLyrics *lyrics.Lyrics) error {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".mp3":
+		return TagFileWithSpotifyMetadata(fileName, trackData, coverArt, sourceURL, trackLyrics)
+	case ".ogg", ".opus", ".flac":
+		return tagVorbisFile(ctx, fileName, trackData, coverArt, sourceURL, trackLyrics)
+	case ".m4a", ".mp4", ".aac":
+		return tagMP4File(ctx, fileName, trackData, coverArt, sourceURL, trackLyrics)
+	default:
+		return fmt.Errorf("unsupported file type for tagging: %s", fileName)
+	}
+}
+
+// TagFileWithSpotifyMetadata applies metadata (title, artists, album, album artist, track and
+// disc number, release date, ISRC, cover art) to an MP3 file as ID3v2.4 frames. The Spotify
+// IDs and sourceURL go into TXXX frames, the lyrics into USLT and SYLT frames.
+func TagFileWithSpotifyMetadata(fileName string, trackData spotify.FullTrack, coverArt []byte, sourceURL string, trackLyrics *lyrics.Lyrics) error {
 	cleanTitle := removeUnsupportedRunes(trackData.Name)
-	cleanArtist := removeUnsupportedRunes(joinArtists(trackData.Album.Artists))
+	cleanArtist := removeUnsupportedRunes(joinArtists(trackData.Artists))
 	cleanAlbum := removeUnsupportedRunes(trackData.Album.Name)
 
 	mp3File, err := id3v2.Open(fileName, id3v2.Options{Parse: true})
@@ -27,20 +59,46 @@ func TagFileWithSpotifyMetadata(fileName string, trackData spotify.FullTrack, co
 		}
 	}()
 
+	// TDRC (full release date) only exists in ID3v2.4
+	mp3File.SetVersion(4)
+	mp3File.DeleteFrames("TYER")
+	mp3File.DeleteFrames("TDAT")
+
 	// Impostiamo i campi base
 	mp3File.SetTitle(cleanTitle)
 	mp3File.SetArtist(cleanArtist)
 	mp3File.SetAlbum(cleanAlbum)
 
-	// Year
-	year := extractYear(trackData.Album.ReleaseDate)
-	mp3File.SetYear(strconv.Itoa(year))
+	encoding := mp3File.DefaultEncoding()
+	setText := func(id string, text string) {
+		mp3File.DeleteFrames(id)
+		if text != "" {
+			mp3File.AddTextFrame(id, encoding, text)
+		}
+	}
+	setText("TPE2", removeUnsupportedRunes(albumArtist(trackData)))
+	setText("TRCK", trackNumber(trackData))
+	setText("TPOS", discNumber(trackData))
+	setText("TDRC", releaseDate(trackData))
+	setText("TSRC", utils.TrackISRC(trackData))
+
+	// Frames from an older tagging of the file must not survive
+	mp3File.DeleteFrames("TXXX")
+	for _, f := range identifierFields(trackData, sourceURL) {
+		mp3File.AddUserDefinedTextFrame(id3v2.UserDefinedTextFrame{
+			Encoding:    encoding,
+			Description: f.key,
+			Value:       f.value,
+		})
+	}
+
+	addLyricsFrames(mp3File, trackLyrics)
 
 	// Se abbiamo una coverArt condivisa (non nil), la usiamo
 	if coverArt != nil && len(coverArt) > 0 {
 		pic := id3v2.PictureFrame{
 			Encoding:    id3v2.EncodingUTF8,
-			MimeType:    "image/jpeg",
+			MimeType:    imageMIMEType(coverArt),
 			PictureType: id3v2.PTFrontCover,
 			Description: "Front cover",
 			Picture:     coverArt,
@@ -65,10 +123,70 @@ func joinArtists(artists []spotify.SimpleArtist) string {
 	return strings.Join(names, ", ")
 }
 
+// albumArtist returns the album artists, or the track artists when Spotify gave none.
+func albumArtist(trackData spotify.FullTrack) string {
+	if len(trackData.Album.Artists) > 0 {
+		return joinArtists(trackData.Album.Artists)
+	}
+	return joinArtists(trackData.Artists)
+}
+
+// trackNumber returns "n/total", or just "n" when the album size is unknown.
+func trackNumber(trackData spotify.FullTrack) string {
+	if trackData.TrackNumber <= 0 {
+		return ""
+	}
+	if trackData.Album.TotalTracks > 0 {
+		return fmt.Sprintf("%d/%d", trackData.TrackNumber, trackData.Album.TotalTracks)
+	}
+	return strconv.Itoa(int(trackData.TrackNumber))
+}
+
+func discNumber(trackData spotify.FullTrack) string {
+	if trackData.DiscNumber <= 0 {
+		return ""
+	}
+	return strconv.Itoa(int(trackData.DiscNumber))
+}
+
+// releaseDate returns the release date as precise as Spotify knows it
+// (2006, 2006-01 or 2006-01-02), which are all valid ID3v2.4 timestamps.
+func releaseDate(trackData spotify.FullTrack) string {
+	if extractYear(trackData.Album.ReleaseDate) == 0 {
+		return ""
+	}
+	return trackData.Album.ReleaseDate
+}
+
+// identifierFields returns the Spotify IDs of the track and the source URL, skipping the empty ones.
+func identifierFields(trackData spotify.FullTrack, sourceURL string) []field {
+	var artistIDs []string
+	for _, a := range trackData.Artists {
+		if a.ID != "" {
+			artistIDs = append(artistIDs, string(a.ID))
+		}
+	}
+
+	var fields []field
+	for _, f := range []field{
+		{SpotifyTrackID, string(trackData.ID)},
+		{SpotifyAlbumID, string(trackData.Album.ID)},
+		{SpotifyArtistID, strings.Join(artistIDs, ", ")},
+		{YouTubeURL, sourceURL},
+	} {
+		if f.value != "" {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
 func extractYear(dateStr string) int {
 	layout := "2006-01-02"
 	if len(dateStr) == 4 {
 		dateStr += "-01-01"
+	} else if len(dateStr) == 7 {
+		dateStr += "-01"
 	}
 	t, err := time.Parse(layout, dateStr)
 	if err != nil {
@@ -0,0 +1,96 @@
+package tags
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bogem/id3v2"
+	"github.com/zmb3/spotify/v2"
+)
+
+func TestTagFileWithSpotifyMetadataRoundTrip(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "track.mp3")
+	if err := os.WriteFile(fileName, []byte("not really audio"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	track := spotify.FullTrack{
+		SimpleTrack: spotify.SimpleTrack{
+			ID:          "trackid",
+			Name:        "Song",
+			Artists:     []spotify.SimpleArtist{{ID: "artist1", Name: "Artist"}, {ID: "artist2", Name: "Guest"}},
+			TrackNumber: 3,
+			DiscNumber:  2,
+		},
+		Album: spotify.SimpleAlbum{
+			ID:          "albumid",
+			Name:        "Album",
+			Artists:     []spotify.SimpleArtist{{Name: "Various Artists"}},
+			ReleaseDate: "2006-01-02",
+			TotalTracks: 12,
+		},
+		ExternalIDs: map[string]string{"isrc": "USAAA0600001"},
+	}
+	cover := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	if err := TagFileWithSpotifyMetadata(fileName, track, cover, "https://www.youtube.com/watch?v=vid", nil); err != nil {
+		t.Fatalf("TagFileWithSpotifyMetadata: %v", err)
+	}
+	// Tagging twice must not pile up frames
+	if err := TagFileWithSpotifyMetadata(fileName, track, nil, "https://www.youtube.com/watch?v=vid", nil); err != nil {
+		t.Fatalf("second TagFileWithSpotifyMetadata: %v", err)
+	}
+
+	tag, err := id3v2.Open(fileName, id3v2.Options{Parse: true})
+	if err != nil {
+		t.Fatalf("id3v2.Open: %v", err)
+	}
+	defer tag.Close()
+
+	if tag.Version() != 4 {
+		t.Errorf("ID3 version = %d, want 4", tag.Version())
+	}
+	texts := map[string]string{
+		"TIT2": "Song",
+		"TPE1": "Artist, Guest",
+		"TALB": "Album",
+		"TPE2": "Various Artists",
+		"TRCK": "3/12",
+		"TPOS": "2",
+		"TDRC": "2006-01-02",
+		"TSRC": "USAAA0600001",
+	}
+	for id, want := range texts {
+		if got := tag.GetTextFrame(id).Text; got != want {
+			t.Errorf("%s = %q, want %q", id, got, want)
+		}
+	}
+
+	want := map[string]string{
+		SpotifyTrackID:  "trackid",
+		SpotifyAlbumID:  "albumid",
+		SpotifyArtistID: "artist1, artist2",
+		YouTubeURL:      "https://www.youtube.com/watch?v=vid",
+	}
+	frames := tag.GetFrames(tag.CommonID("User defined text information frame"))
+	if len(frames) != len(want) {
+		t.Errorf("got %d TXXX frames, want %d", len(frames), len(want))
+	}
+	for _, f := range frames {
+		udtf, ok := f.(id3v2.UserDefinedTextFrame)
+		if !ok {
+			t.Fatalf("TXXX frame of type %T", f)
+		}
+		if udtf.Value != want[udtf.Description] {
+			t.Errorf("TXXX %s = %q, want %q", udtf.Description, udtf.Value, want[udtf.Description])
+		}
+	}
+
+	pictures := tag.GetFrames(tag.CommonID("Attached picture"))
+	if len(pictures) != 1 {
+		t.Fatalf("got %d pictures, want 1", len(pictures))
+	}
+	if pic := pictures[0].(id3v2.PictureFrame); pic.MimeType != "image/png" || string(pic.Picture) != string(cover) {
+		t.Errorf("picture %s of %d bytes, want the PNG cover", pic.MimeType, len(pic.Picture))
+	}
+}
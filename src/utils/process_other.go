@@ -0,0 +1,17 @@
+//go:build !unix
+
+package utils
+
+import (
+	"context"
+	"os/exec"
+	"time"
+)
+
+// commandContext returns a command killed when ctx is done.
+func commandContext(ctx context.Context, command string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, command, args...)
+	// Children holding the output pipes must not keep Wait blocked
+	cmd.WaitDelay = 5 * time.Second
+	return cmd
+}
@@ -0,0 +1,23 @@
+//go:build unix
+
+package utils
+
+import (
+	"context"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// commandContext returns a command killed together with its children when ctx is done:
+// the command gets its own process group and the whole group is killed.
+func commandContext(ctx context.Context, command string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, command, args...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	// Children holding the output pipes must not keep Wait blocked
+	cmd.WaitDelay = 5 * time.Second
+	return cmd
+}
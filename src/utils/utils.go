@@ -2,15 +2,17 @@ package utils
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"regexp"
 	"strings"
 	"time"
+
+	"github.com/zmb3/spotify/v2"
 )
 
 // DownloadFile scarica una risorsa da URL e restituisce i byte
@@ -38,13 +40,21 @@ func DownloadFile(url string) ([]byte, error) {
 
 // RunCmd esegue un comando esterno con i relativi argomenti e ne ritorna l'output o un errore
 func RunCmd(command string, args ...string) ([]byte, error) {
-	cmd := exec.Command(command, args...)
+	return RunCmdContext(context.Background(), command, args...)
+}
+
+// RunCmdContext is RunCmd, killing the command and its children when ctx is done.
+func RunCmdContext(ctx context.Context, command string, args ...string) ([]byte, error) {
+	cmd := commandContext(ctx, command, args...)
 	var out bytes.Buffer
 	var stderr bytes.Buffer
 	cmd.Stdout = &out
 	cmd.Stderr = &stderr
 
 	err := cmd.Run()
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
 	if err != nil {
 		return nil, fmt.Errorf("%v: %s", err, stderr.String())
 	}
@@ -90,6 +100,26 @@ func Retry(maxRetries int, delay time.Duration, f func() error) error {
 	return fmt.Errorf("all retries failed after %d attempts. Last error: %w", maxRetries, err)
 }
 
+// RetryContext is Retry, giving up as soon as ctx is done.
+func RetryContext(ctx context.Context, maxRetries int, delay time.Duration, f func() error) error {
+	var err error
+	for i := 0; i < maxRetries; i++ {
+		err = f()
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+	return fmt.Errorf("all retries failed after %d attempts. Last error: %w", maxRetries, err)
+}
+
 func DownloadFileWithRetry(url string, maxRetries int, delay time.Duration) ([]byte, error) {
 	var data []byte
 
@@ -122,21 +152,54 @@ func DownloadFileWithRetry(url string, maxRetries int, delay time.Duration) ([]b
 	return data, nil
 }
 
-func RunCmdWithRetry(command string, args []string, maxRetries int, delay time.Duration) ([]byte, error) {
-	var out []byte
-	err := Retry(maxRetries, delay, func() error {
-		cmd := exec.Command(command, args...)
-		output, cmdErr := cmd.CombinedOutput()
-		if cmdErr != nil {
-			return fmt.Errorf("cmd failed: %v\noutput: %s", cmdErr, string(output))
+// RunCmdLines runs the command once, calling onLine with every line of output (stdout and
+// stderr) as soon as the command prints it. onLine may be nil. On failure the error carries
+// the whole output.
+func RunCmdLines(ctx context.Context, command string, args []string, onLine func(line string)) ([]byte, error) {
+	cmd := commandContext(ctx, command, args...)
+	output := &lineWriter{onLine: onLine}
+	// The same writer for both: exec calls it from one goroutine at a time
+	cmd.Stdout = output
+	cmd.Stderr = output
+	cmdErr := cmd.Run()
+	output.flush()
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if cmdErr != nil {
+		return nil, fmt.Errorf("cmd failed: %v\noutput: %s", cmdErr, output.all.String())
+	}
+	return output.all.Bytes(), nil
+}
+
+// lineWriter keeps the whole output and hands every complete line to onLine.
+// Carriage returns end a line too, for commands redrawing a progress line.
+type lineWriter struct {
+	onLine  func(line string)
+	all     bytes.Buffer
+	partial []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.all.Write(p)
+	if w.onLine == nil {
+		return len(p), nil
+	}
+	for _, b := range p {
+		if b == '\n' || b == '\r' {
+			w.flush()
+			continue
 		}
-		out = output
-		return nil
-	})
-	if err != nil {
-		return nil, err
+		w.partial = append(w.partial, b)
+	}
+	return len(p), nil
+}
+
+func (w *lineWriter) flush() {
+	if w.onLine != nil && len(w.partial) > 0 {
+		w.onLine(string(w.partial))
 	}
-	return out, nil
+	w.partial = w.partial[:0]
 }
 
 var invalidCharsRegex = regexp.MustCompile(`[\\/:*?"<>|]`)
@@ -160,3 +223,11 @@ func CleanTitleForSearch(raw string) string {
 
 	return strings.TrimSpace(cleaned)
 }
+
+// TrackISRC returns the ISRC of a track, or an empty string if Spotify didn't provide one.
+func TrackISRC(track spotify.FullTrack) string {
+	if isrc := track.ExternalIDs["isrc"]; isrc != "" {
+		return isrc
+	}
+	return track.SimpleTrack.ExternalIDs.ISRC
+}
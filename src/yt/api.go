@@ -0,0 +1,121 @@
+package youtube
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"google.golang.org/api/option"
+	"google.golang.org/api/youtube/v3"
+)
+
+// APISearcher searches through the YouTube Data API. Every search costs quota.
+type APISearcher struct {
+	service *youtube.Service
+}
+
+// NewAPISearcher creates the YouTube Data API service once, to be reused by every search.
+func NewAPISearcher(ctx context.Context, apiKey string) (*APISearcher, error) {
+	if apiKey == "" {
+		return nil, fmt.Errorf("missing YOUTUBE_API_KEY environment variable")
+	}
+
+	service, err := youtube.NewService(ctx, option.WithAPIKey(apiKey), option.WithHTTPClient(httpClient))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create youtube service: %w", err)
+	}
+	return &APISearcher{service: service}, nil
+}
+
+// SpendsQuota is always true: every Data API search costs quota.
+func (a *APISearcher) SpendsQuota() bool {
+	return true
+}
+
+func (a *APISearcher) Search(ctx context.Context, query string, limit int64) ([]*SearchResult, error) {
+	call := a.service.Search.List([]string{"id", "snippet"}).
+		Q(query).
+		Type("video").
+		MaxResults(limit).
+		Context(ctx)
+
+	resp, err := call.Do()
+	if err != nil {
+		return nil, fmt.Errorf("youtube search error: %w", err)
+	}
+
+	if len(resp.Items) == 0 {
+		return nil, nil
+	}
+
+	var results []*SearchResult
+	for _, item := range resp.Items {
+		if item.Id.Kind == "youtube#video" {
+			vid := item.Id.VideoId
+			title := item.Snippet.Title
+			uploader := item.Snippet.ChannelTitle
+
+			results = append(results, &SearchResult{
+				Title:    title,
+				Uploader: uploader,
+				ID:       vid,
+				URL:      "https://youtube.com/watch?v=" + vid,
+				Source:   "youtube",
+			})
+		}
+	}
+
+	return results, nil
+}
+
+func (a *APISearcher) Durations(ctx context.Context, results []*SearchResult) (map[string]int, error) {
+	var ids []string
+	for _, r := range results {
+		ids = append(ids, r.ID)
+	}
+	idStr := strings.Join(ids, ",")
+
+	call := a.service.Videos.List([]string{"contentDetails"}).Id(idStr).Context(ctx)
+	resp, err := call.Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch videos info: %w", err)
+	}
+
+	durations := make(map[string]int)
+	for _, item := range resp.Items {
+		isoDur := item.ContentDetails.Duration
+		seconds := parseISO8601Duration(isoDur)
+		durations[item.Id] = seconds
+	}
+	return durations, nil
+}
+
+// parseISO8601Duration convert a duration string like “PT4M20S” into seconds.
+func parseISO8601Duration(isoDur string) int {
+	isoDur = strings.TrimPrefix(isoDur, "PT")
+
+	var hours, mins, secs int
+	if idx := strings.Index(isoDur, "H"); idx != -1 {
+		_, err := fmt.Sscanf(isoDur[:idx], "%d", &hours)
+		if err != nil {
+			return 0
+		}
+		isoDur = isoDur[idx+1:]
+	}
+	if idx := strings.Index(isoDur, "M"); idx != -1 {
+		_, err := fmt.Sscanf(isoDur[:idx], "%d", &mins)
+		if err != nil {
+			return 0
+		}
+		isoDur = isoDur[idx+1:]
+	}
+	if idx := strings.Index(isoDur, "S"); idx != -1 {
+		_, err := fmt.Sscanf(isoDur[:idx], "%d", &secs)
+		if err != nil {
+			return 0
+		}
+		isoDur = isoDur[idx+1:]
+	}
+
+	return hours*3600 + mins*60 + secs
+}
@@ -0,0 +1,150 @@
+package youtube
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+)
+
+// weightISRC rewards a video found by searching the ISRC of the track and verified against it.
+const weightISRC = 20.0
+
+// isrcSearchLimit is how many videos of the ISRC search are looked at: the right one, if
+// there is one, comes first.
+const isrcSearchLimit = 5
+
+// ISRCSearch decides when SearchTrack searches the ISRC of a track before its title.
+type ISRCSearch string
+
+const (
+	// ISRCSearchAuto searches the ISRC only when searching costs no Data API quota, e.g.
+	// with yt-dlp or once the quota has run out: on the API it could double the searches.
+	ISRCSearchAuto   ISRCSearch = "auto"
+	ISRCSearchAlways ISRCSearch = "always"
+	ISRCSearchNever  ISRCSearch = "never"
+)
+
+func ParseISRCSearch(input string) (ISRCSearch, error) {
+	input = strings.ToLower(input)
+	switch input {
+	case "", "auto":
+		return ISRCSearchAuto, nil
+	case "always":
+		return ISRCSearchAlways, nil
+	case "never":
+		return ISRCSearchNever, nil
+	default:
+		return ISRCSearchAuto, fmt.Errorf("invalid ISRC search mode: '%s' (valid: auto, always, never)", input)
+	}
+}
+
+// QuotaSearcher is implemented by the searchers whose searches may cost Data API quota.
+type QuotaSearcher interface {
+	// SpendsQuota reports whether the next search costs quota.
+	SpendsQuota() bool
+}
+
+// searchesISRC reports whether SearchTrack tries the ISRC with searcher in the given mode.
+func searchesISRC(searcher VideoSearcher, mode ISRCSearch) bool {
+	switch mode {
+	case ISRCSearchAlways:
+		return true
+	case ISRCSearchNever:
+		return false
+	}
+	q, ok := searcher.(QuotaSearcher)
+	return !ok || !q.SpendsQuota()
+}
+
+// ISRCQuery is the search query finding the videos of the recording with the given ISRC,
+// which the official "- Topic" uploads often carry in their description.
+func ISRCQuery(isrc string) string {
+	return `"` + strings.ToUpper(isrc) + `"`
+}
+
+// SearchTrack ranks the videos for target, best first, and returns the query they come from.
+// When target has an ISRC and mode allows it, the videos found by searching it are tried
+// first. Any video can mention an ISRC, so they are only used if one of them is verified
+// against target; otherwise the results of query are ranked as in RankVideos.
+func SearchTrack(ctx context.Context, searcher VideoSearcher, query string, target Target, mode ISRCSearch) (string, []Candidate, error) {
+	if target.ISRC != "" && searchesISRC(searcher, mode) {
+		isrcQuery := ISRCQuery(target.ISRC)
+		candidates, err := rankISRCVideos(ctx, searcher, isrcQuery, target)
+		switch {
+		case ctx.Err() != nil:
+			return isrcQuery, nil, ctx.Err()
+		case IsQuotaExceeded(err):
+			return isrcQuery, nil, err
+		case err != nil:
+			log.Printf("ISRC search failed for %s, searching by title: %v", target.ISRC, err)
+		case len(candidates) > 0:
+			return isrcQuery, candidates, nil
+		}
+	}
+
+	candidates, err := RankVideos(ctx, searcher, query, target)
+	return query, candidates, err
+}
+
+// rankISRCVideos searches isrcQuery and ranks the results against target. The verified ones
+// get weightISRC; with none verified, no candidate is returned.
+func rankISRCVideos(ctx context.Context, searcher VideoSearcher, isrcQuery string, target Target) ([]Candidate, error) {
+	results, err := searcher.Search(ctx, isrcQuery, isrcSearchLimit)
+	if err != nil || len(results) == 0 {
+		return nil, err
+	}
+	durations, err := searcher.Durations(ctx, results)
+	if err != nil {
+		// Without durations the verification still checks the title and the artist
+		durations = nil
+	}
+
+	candidates := RankCandidates(results, durations, target)
+	verified := false
+	for i := range candidates {
+		if verifyCandidate(candidates[i], target) {
+			candidates[i].Score += weightISRC
+			candidates[i].Reasons = append(candidates[i].Reasons, fmt.Sprintf("found by ISRC %s (%+.1f)", target.ISRC, weightISRC))
+			verified = true
+		}
+	}
+	if !verified {
+		return nil, nil
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Score > candidates[j].Score
+	})
+	return candidates, nil
+}
+
+// verifyCandidate reports whether the video is the track of target: its title and artist
+// (in the video title or the channel name) match, the duration is close when known and the
+// title names no alternative version the track isn't.
+func verifyCandidate(c Candidate, target Target) bool {
+	videoTitle := normalizeForMatch(c.Result.Title)
+	if tokenContainment(normalizeForMatch(cleanTitleForMatch(target.Title)), videoTitle) < 0.8 {
+		return false
+	}
+	if len(target.Artists) > 0 {
+		artist := normalizeForMatch(target.Artists[0])
+		channel := normalizeForMatch(c.Result.Uploader)
+		if math.Max(tokenContainment(artist, videoTitle), tokenContainment(artist, channel)) < 0.8 {
+			return false
+		}
+	}
+	if c.Duration > 0 && target.DurationSeconds > 0 &&
+		math.Abs(float64(c.Duration-target.DurationSeconds)) > float64(durationMatchThreshold) {
+		return false
+	}
+	spotifyTitle := normalizeForMatch(target.Title)
+	for _, word := range variantWords {
+		if containsWord(videoTitle, word) && !containsWord(spotifyTitle, word) {
+			return false
+		}
+	}
+	return !c.Result.Live
+}
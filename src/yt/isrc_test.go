@@ -0,0 +1,110 @@
+package youtube
+
+import (
+	"context"
+	"testing"
+)
+
+// quotaSearcher is a fakeSearcher whose searches cost Data API quota.
+type quotaSearcher struct {
+	*fakeSearcher
+}
+
+func (quotaSearcher) SpendsQuota() bool {
+	return true
+}
+
+var isrcTarget = Target{
+	Title:           "Never Gonna Give You Up",
+	Artists:         []string{"Rick Astley"},
+	DurationSeconds: 213,
+	ISRC:            "GBARL9300135",
+}
+
+func topicResults() []*SearchResult {
+	return []*SearchResult{
+		{ID: "topic", Title: "Never Gonna Give You Up", Uploader: "Rick Astley - Topic"},
+		{ID: "cover", Title: "Never Gonna Give You Up (Cover)", Uploader: "Covers"},
+	}
+}
+
+func TestSearchTrackISRCMode(t *testing.T) {
+	tests := []struct {
+		name        string
+		spendsQuota bool
+		mode        ISRCSearch
+	}{
+		{"auto without quota", false, ISRCSearchAuto},
+		{"auto with quota", true, ISRCSearchAuto},
+		{"always with quota", true, ISRCSearchAlways},
+		{"never", false, ISRCSearchNever},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeSearcher{results: topicResults(), durations: map[string]int{"topic": 213, "cover": 200}}
+			var searcher VideoSearcher = fake
+			if tt.spendsQuota {
+				searcher = quotaSearcher{fake}
+			}
+
+			query, candidates, err := SearchTrack(context.Background(), searcher, "rick astley never gonna give you up", isrcTarget, tt.mode)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			wantISRC := tt.mode == ISRCSearchAlways || tt.mode == ISRCSearchAuto && !tt.spendsQuota
+			wantQuery := "rick astley never gonna give you up"
+			if wantISRC {
+				wantQuery = `"GBARL9300135"`
+			}
+			if query != wantQuery || len(fake.queries) != 1 || fake.queries[0] != wantQuery {
+				t.Errorf("got query %q after searching %q, want only %q", query, fake.queries, wantQuery)
+			}
+			if candidates[0].Result.ID != "topic" {
+				t.Errorf("best candidate is %q, want topic", candidates[0].Result.ID)
+			}
+		})
+	}
+}
+
+func TestSearchTrackUnverifiedISRC(t *testing.T) {
+	// Only a cover mentions the ISRC: the title search runs
+	fake := &fakeSearcher{results: []*SearchResult{{ID: "cover", Title: "Never Gonna Give You Up (Cover)", Uploader: "Covers"}}}
+
+	query, _, err := SearchTrack(context.Background(), fake, "rick astley never gonna give you up", isrcTarget, ISRCSearchAlways)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if query != "rick astley never gonna give you up" || len(fake.queries) != 2 {
+		t.Errorf("got query %q after searching %q, want the ISRC then the title", query, fake.queries)
+	}
+}
+
+func TestFallbackSearcherSpendsQuota(t *testing.T) {
+	primary := quotaSearcher{&fakeSearcher{err: errQuota}}
+	f := &FallbackSearcher{Primary: primary, Fallback: &fakeSearcher{}}
+	if !f.SpendsQuota() {
+		t.Fatal("SpendsQuota is false before the quota runs out")
+	}
+	if _, err := f.Search(context.Background(), "query", 10); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if f.SpendsQuota() {
+		t.Error("SpendsQuota is still true after switching to the fallback")
+	}
+
+	if (&FallbackSearcher{Primary: &fakeSearcher{}, Fallback: &fakeSearcher{}}).SpendsQuota() {
+		t.Error("SpendsQuota is true with a primary costing no quota")
+	}
+}
+
+func TestParseISRCSearch(t *testing.T) {
+	for input, want := range map[string]ISRCSearch{"": ISRCSearchAuto, "AUTO": ISRCSearchAuto, "always": ISRCSearchAlways, "never": ISRCSearchNever} {
+		if got, err := ParseISRCSearch(input); err != nil || got != want {
+			t.Errorf("ParseISRCSearch(%q) = %q, %v, want %q", input, got, err, want)
+		}
+	}
+	if _, err := ParseISRCSearch("sometimes"); err == nil {
+		t.Error("ParseISRCSearch accepted an invalid mode")
+	}
+}
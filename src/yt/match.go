@@ -0,0 +1,251 @@
+package youtube
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Target describes the track a video has to match.
+type Target struct {
+	Title           string
+	Artists         []string
+	DurationSeconds int
+	// ISRC identifies the recording, empty if unknown; see SearchTrack
+	ISRC string
+}
+
+// Candidate is a search result with its match score. Reasons lists how the score was built.
+type Candidate struct {
+	Result   *SearchResult
+	Duration int // seconds, 0 if unknown
+	Score    float64
+	Reasons  []string
+}
+
+// Score weights. A perfect match (exact duration, full title, artist and official
+// channel) scores about 100; penalties can push a candidate below zero.
+const (
+	weightDuration      = 35.0
+	weightTitle         = 30.0
+	weightArtist        = 15.0
+	weightTopicChannel  = 15.0
+	weightVevoChannel   = 8.0
+	weightArtistChannel = 8.0
+	weightPosition      = 5.0
+	penaltyVariant      = 25.0
+	penaltyLiveStream   = 50.0
+	penaltyWayTooLong   = 40.0
+)
+
+// variantWords mark alternative versions of a song. A video is penalized for each of them
+// found in its title, unless the Spotify title contains it too.
+var variantWords = []string{
+	"live", "cover", "remix", "karaoke", "slowed", "sped up", "speed up", "reverb", "nightcore",
+	"instrumental", "acoustic", "8d", "bass boosted", "reaction", "tutorial", "10 hours", "1 hour",
+}
+
+// ErrNoResults is returned when the search finds no video at all.
+var ErrNoResults = errors.New("no songs found")
+
+var nonAlphanumericRegex = regexp.MustCompile(`[^\p{L}\p{N}]+`)
+
+// RankVideos searches the query and returns all the results ranked against target, best first.
+func RankVideos(ctx context.Context, searcher VideoSearcher, searchQuery string, target Target) ([]Candidate, error) {
+	results, err := searcher.Search(ctx, searchQuery, 10)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("%w for %s", ErrNoResults, searchQuery)
+	}
+
+	durations, err := searcher.Durations(ctx, results)
+	if err != nil {
+		// Durations only refine the ranking: go on without them
+		durations = nil
+	}
+
+	return RankCandidates(results, durations, target), nil
+}
+
+// RankCandidates scores every result against target and sorts them by descending score.
+// Results with the same score keep the order given by the search engine.
+func RankCandidates(results []*SearchResult, durations map[string]int, target Target) []Candidate {
+	candidates := make([]Candidate, 0, len(results))
+	for i, r := range results {
+		c := Candidate{Result: r, Duration: durations[r.ID]}
+		c.Score, c.Reasons = scoreCandidate(r, c.Duration, i, len(results), target)
+		candidates = append(candidates, c)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Score > candidates[j].Score
+	})
+	return candidates
+}
+
+func scoreCandidate(r *SearchResult, duration int, position int, total int, target Target) (float64, []string) {
+	var score float64
+	var reasons []string
+	add := func(points float64, format string, args ...interface{}) {
+		score += points
+		reasons = append(reasons, fmt.Sprintf("%s (%+.1f)", fmt.Sprintf(format, args...), points))
+	}
+
+	videoTitle := normalizeForMatch(r.Title)
+	channel := normalizeForMatch(r.Uploader)
+	spotifyTitle := normalizeForMatch(target.Title)
+
+	// Duration distance: full points within the threshold, then a linear decay to zero at 30s
+	if duration > 0 && target.DurationSeconds > 0 {
+		diff := int(math.Abs(float64(duration - target.DurationSeconds)))
+		switch {
+		case diff <= durationMatchThreshold:
+			add(weightDuration, "duration %s matches (%ds off)", FormatVideoDuration(duration), diff)
+		case diff < 30:
+			add(weightDuration*(1-float64(diff)/30), "duration %s is %ds off", FormatVideoDuration(duration), diff)
+		case duration > 2*target.DurationSeconds:
+			add(-penaltyWayTooLong, "duration %s is way too long (loop or compilation?)", FormatVideoDuration(duration))
+		default:
+			add(0, "duration %s is %ds off", FormatVideoDuration(duration), diff)
+		}
+	} else {
+		add(0, "duration unknown")
+	}
+
+	// Fuzzy title similarity
+	titleSim := tokenContainment(normalizeForMatch(cleanTitleForMatch(target.Title)), videoTitle)
+	add(weightTitle*titleSim, "title similarity %.0f%%", titleSim*100)
+
+	// Artist in the video title or in the channel name
+	artistSim := 0.0
+	mainArtist := ""
+	if len(target.Artists) > 0 {
+		mainArtist = normalizeForMatch(target.Artists[0])
+		artistSim = math.Max(tokenContainment(mainArtist, videoTitle), tokenContainment(mainArtist, channel))
+	}
+	add(weightArtist*artistSim, "artist similarity %.0f%%", artistSim*100)
+
+	// Channel signals
+	lowerUploader := strings.ToLower(r.Uploader)
+	switch {
+	case strings.HasSuffix(lowerUploader, "- topic"):
+		add(weightTopicChannel, "official \"- Topic\" channel")
+	case strings.Contains(lowerUploader, "vevo"):
+		add(weightVevoChannel, "VEVO channel")
+	}
+	if mainArtist != "" && strings.Contains(strings.ReplaceAll(channel, " ", ""), strings.ReplaceAll(mainArtist, " ", "")) {
+		add(weightArtistChannel, "channel matches artist")
+	}
+
+	// Alternative versions the Spotify track isn't
+	for _, word := range variantWords {
+		if containsWord(videoTitle, word) && !containsWord(spotifyTitle, word) {
+			add(-penaltyVariant, "title says %q", word)
+		}
+	}
+	if r.Live {
+		add(-penaltyLiveStream, "live stream")
+	}
+
+	// Keep a little trust in the search engine's own ordering
+	if total > 1 {
+		add(weightPosition*float64(total-1-position)/float64(total-1), "search position %d", position+1)
+	}
+
+	return score, reasons
+}
+
+var parensRegex = regexp.MustCompile(`\([^)]*\)|\[[^]]*]`)
+
+// cleanTitleForMatch drops the parts of a Spotify title that rarely appear in video titles,
+// like "(feat. X)" or " - Remastered 2011".
+func cleanTitleForMatch(title string) string {
+	title = parensRegex.ReplaceAllString(title, "")
+	if idx := strings.Index(title, " - "); idx > 0 {
+		title = title[:idx]
+	}
+	return title
+}
+
+// Similarity returns, from 0 to 1, how much of title is found in other, with the same fuzzy
+// matching used for video titles. Parts of title like "(feat. X)" are left out.
+func Similarity(title string, other string) float64 {
+	return tokenContainment(normalizeForMatch(cleanTitleForMatch(title)), normalizeForMatch(other))
+}
+
+func normalizeForMatch(s string) string {
+	s = strings.ToLower(s)
+	s = nonAlphanumericRegex.ReplaceAllString(s, " ")
+	return strings.TrimSpace(s)
+}
+
+func containsWord(normalized string, word string) bool {
+	return strings.Contains(" "+normalized+" ", " "+word+" ")
+}
+
+// tokenContainment returns the fraction of the tokens of needle found in haystack.
+// Tokens match fuzzily, so small spelling differences still count.
+func tokenContainment(needle string, haystack string) float64 {
+	needleTokens := strings.Fields(needle)
+	haystackTokens := strings.Fields(haystack)
+	if len(needleTokens) == 0 || len(haystackTokens) == 0 {
+		return 0
+	}
+
+	var matched float64
+	for _, n := range needleTokens {
+		best := 0.0
+		for _, h := range haystackTokens {
+			if sim := stringSimilarity(n, h); sim > best {
+				best = sim
+			}
+		}
+		// Below 0.8 two words are just different words
+		if best >= 0.8 {
+			matched += best
+		}
+	}
+	return matched / float64(len(needleTokens))
+}
+
+// stringSimilarity returns 1 - normalized Levenshtein distance between a and b.
+func stringSimilarity(a string, b string) float64 {
+	if a == b {
+		return 1
+	}
+	ra, rb := []rune(a), []rune(b)
+	maxLen := len(ra)
+	if len(rb) > maxLen {
+		maxLen = len(rb)
+	}
+	if maxLen == 0 {
+		return 1
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(maxLen)
+}
+
+func levenshtein(a []rune, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,101 @@
+package youtube
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+var rickTarget = Target{
+	Title:           "Never Gonna Give You Up",
+	Artists:         []string{"Rick Astley"},
+	DurationSeconds: 213,
+}
+
+func TestRankCandidates(t *testing.T) {
+	tests := []struct {
+		name      string
+		results   []*SearchResult
+		durations map[string]int
+		want      []string
+	}{
+		{
+			name: "topic channel beats a live version listed first",
+			results: []*SearchResult{
+				{ID: "live", Title: "Rick Astley - Never Gonna Give You Up (Live)", Uploader: "Some Fan"},
+				{ID: "topic", Title: "Never Gonna Give You Up", Uploader: "Rick Astley - Topic"},
+			},
+			durations: map[string]int{"live": 240, "topic": 213},
+			want:      []string{"topic", "live"},
+		},
+		{
+			name: "ten hour loop and cover rank last",
+			results: []*SearchResult{
+				{ID: "loop", Title: "Rick Astley - Never Gonna Give You Up 10 hours", Uploader: "Loops"},
+				{ID: "cover", Title: "Never Gonna Give You Up (cover)", Uploader: "Covers"},
+				{ID: "official", Title: "Rick Astley - Never Gonna Give You Up (Official Video)", Uploader: "RickAstleyVEVO"},
+			},
+			durations: map[string]int{"loop": 36000, "cover": 210, "official": 212},
+			want:      []string{"official", "cover", "loop"},
+		},
+		{
+			name: "same score keeps the search order",
+			results: []*SearchResult{
+				{ID: "first", Title: "Unrelated", Uploader: "A"},
+				{ID: "second", Title: "Unrelated", Uploader: "A"},
+				{ID: "third", Title: "Unrelated", Uploader: "A"},
+			},
+			want: []string{"first", "second", "third"},
+		},
+		{
+			name: "stream is penalized",
+			results: []*SearchResult{
+				{ID: "stream", Title: "Rick Astley - Never Gonna Give You Up", Uploader: "Rick Astley", Live: true},
+				{ID: "video", Title: "Rick Astley - Never Gonna Give You Up", Uploader: "Rick Astley"},
+			},
+			want: []string{"video", "stream"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			candidates := RankCandidates(tt.results, tt.durations, rickTarget)
+			if len(candidates) != len(tt.want) {
+				t.Fatalf("got %d candidates, want %d", len(candidates), len(tt.want))
+			}
+			for i, id := range tt.want {
+				if candidates[i].Result.ID != id {
+					t.Errorf("candidate %d is %q (score %.1f), want %q", i, candidates[i].Result.ID, candidates[i].Score, id)
+				}
+				if i > 0 && candidates[i].Score > candidates[i-1].Score {
+					t.Errorf("candidate %d scores more than candidate %d", i, i-1)
+				}
+			}
+		})
+	}
+}
+
+func TestRankVideos(t *testing.T) {
+	searcher := &fakeSearcher{
+		results: []*SearchResult{
+			{ID: "karaoke", Title: "Never Gonna Give You Up (Karaoke Version)", Uploader: "Karaoke Hits"},
+			{ID: "topic", Title: "Never Gonna Give You Up", Uploader: "Rick Astley - Topic"},
+		},
+		durations: map[string]int{"karaoke": 213, "topic": 214},
+	}
+
+	candidates, err := RankVideos(context.Background(), searcher, "rick astley never gonna give you up", rickTarget)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if candidates[0].Result.ID != "topic" || candidates[0].Duration != 214 {
+		t.Errorf("best candidate is %q (%ds), want topic (214s)", candidates[0].Result.ID, candidates[0].Duration)
+	}
+	if len(searcher.queries) != 1 || searcher.queries[0] != "rick astley never gonna give you up" {
+		t.Errorf("searched %v, want the query once", searcher.queries)
+	}
+
+	if _, err := RankVideos(context.Background(), &fakeSearcher{}, "nothing", rickTarget); !errors.Is(err, ErrNoResults) {
+		t.Errorf("got error %v, want ErrNoResults", err)
+	}
+}
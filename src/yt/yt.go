@@ -3,16 +3,71 @@ package youtube
 import (
 	"context"
 	"fmt"
-	"google.golang.org/api/option"
-	"google.golang.org/api/youtube/v3"
+	"log"
 	"net/http"
 	"os"
 	"strings"
+	"sync/atomic"
 )
 
 var httpClient = &http.Client{}
 var durationMatchThreshold = 5
 
+// VideoSearcher finds YouTube videos for a search query and looks up their durations.
+// The Data API and yt-dlp backends implement it; library users can plug in their own.
+type VideoSearcher interface {
+	// Search returns up to limit videos matching query, best matches first.
+	Search(ctx context.Context, query string, limit int64) ([]*SearchResult, error)
+	// Durations returns the duration in seconds of each result, keyed by video ID.
+	Durations(ctx context.Context, results []*SearchResult) (map[string]int, error)
+}
+
+type SearchBackend string
+
+const (
+	// BackendAuto uses the YouTube Data API when YOUTUBE_API_KEY is set and switches to
+	// yt-dlp when the key is missing or the daily quota runs out.
+	BackendAuto  SearchBackend = "auto"
+	BackendAPI   SearchBackend = "api"
+	BackendYtDlp SearchBackend = "ytdlp"
+)
+
+func ParseSearchBackend(input string) (SearchBackend, error) {
+	input = strings.ToLower(input)
+	switch input {
+	case "", "auto":
+		return BackendAuto, nil
+	case "api", "youtube":
+		return BackendAPI, nil
+	case "ytdlp", "yt-dlp":
+		return BackendYtDlp, nil
+	default:
+		return BackendAuto, fmt.Errorf("invalid search backend: '%s' (valid: auto, api, ytdlp)", input)
+	}
+}
+
+// NewSearcher builds the VideoSearcher for the given backend, reading the API key
+// from the YOUTUBE_API_KEY environment variable.
+func NewSearcher(ctx context.Context, backend SearchBackend) (VideoSearcher, error) {
+	apiKey := os.Getenv("YOUTUBE_API_KEY")
+
+	switch backend {
+	case BackendYtDlp:
+		return &YtDlpSearcher{}, nil
+	case BackendAPI:
+		return NewAPISearcher(ctx, apiKey)
+	default:
+		if apiKey == "" {
+			return &YtDlpSearcher{}, nil
+		}
+		api, err := NewAPISearcher(ctx, apiKey)
+		if err != nil {
+			return nil, err
+		}
+		return &FallbackSearcher{Primary: api, Fallback: &YtDlpSearcher{}}, nil
+	}
+}
+
 type SearchResult struct {
 	Title     string
 	Uploader  string
@@ -24,137 +79,102 @@ type SearchResult struct {
 	ExtraInfo []string
 }
 
-// FindClosestMatchingVideo returns the best-match YouTube video ID for a given query.
-func FindClosestMatchingVideo(searchQuery string, durationSeconds int) (string, error) {
-	results, err := searchYouTubeAPI(searchQuery, 10)
-	if err != nil {
-		return "", err
-	}
-	if len(results) == 0 {
-		return "", fmt.Errorf("no songs found for %s", searchQuery)
-	}
+// FallbackSearcher uses Primary until it answers with quotaExceeded,
+// then sends every following request to Fallback.
+type FallbackSearcher struct {
+	Primary  VideoSearcher
+	Fallback VideoSearcher
+
+	quotaExhausted atomic.Bool
+}
 
-	durationsMap, err := fetchDurationsForVideos(results) // vedi sotto
-	if err == nil {
-		for _, result := range results {
-			dur := durationsMap[result.ID]
-			if dur > 0 {
-				allowedStart := durationSeconds - durationMatchThreshold
-				allowedEnd := durationSeconds + durationMatchThreshold
-				if dur >= allowedStart && dur <= allowedEnd {
-					return result.ID, nil
-				}
-			}
+func (f *FallbackSearcher) Search(ctx context.Context, query string, limit int64) ([]*SearchResult, error) {
+	if !f.quotaExhausted.Load() {
+		results, err := f.Primary.Search(ctx, query, limit)
+		if !f.switchOnQuota(err) {
+			return results, err
 		}
 	}
-
-	// Se non c’è corrispondenza di durata, fallback al primo risultato
-	return results[0].ID, nil
+	return f.Fallback.Search(ctx, query, limit)
 }
 
-func searchYouTubeAPI(query string, limit int64) ([]*SearchResult, error) {
-	apiKey := os.Getenv("YOUTUBE_API_KEY")
-	if apiKey == "" {
-		return nil, fmt.Errorf("missing YOUTUBE_API_KEY environment variable")
+func (f *FallbackSearcher) Durations(ctx context.Context, results []*SearchResult) (map[string]int, error) {
+	// Results found by the fallback already carry their duration
+	if len(results) > 0 && results[0].Duration != "" {
+		return f.Fallback.Durations(ctx, results)
 	}
-
-	ctx := context.Background()
-	service, err := youtube.NewService(ctx, option.WithAPIKey(apiKey))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create youtube service: %w", err)
+	if !f.quotaExhausted.Load() {
+		durations, err := f.Primary.Durations(ctx, results)
+		if !f.switchOnQuota(err) {
+			return durations, err
+		}
 	}
+	return f.Fallback.Durations(ctx, results)
+}
 
-	call := service.Search.List([]string{"id", "snippet"}).
-		Q(query).
-		Type("video").
-		MaxResults(limit)
-
-	resp, err := call.Do()
-	if err != nil {
-		return nil, fmt.Errorf("youtube search error: %w", err)
+// SpendsQuota reports whether the searches still go to Primary and cost its quota.
+func (f *FallbackSearcher) SpendsQuota() bool {
+	if q, ok := f.Primary.(QuotaSearcher); ok {
+		return !f.quotaExhausted.Load() && q.SpendsQuota()
 	}
+	return false
+}
 
-	if len(resp.Items) == 0 {
-		return nil, nil
+// switchOnQuota reports whether err is a quota error, switching to the fallback if so.
+func (f *FallbackSearcher) switchOnQuota(err error) bool {
+	if !IsQuotaExceeded(err) {
+		return false
 	}
-
-	var results []*SearchResult
-	for _, item := range resp.Items {
-		if item.Id.Kind == "youtube#video" {
-			vid := item.Id.VideoId
-			title := item.Snippet.Title
-			uploader := item.Snippet.ChannelTitle
-
-			results = append(results, &SearchResult{
-				Title:    title,
-				Uploader: uploader,
-				ID:       vid,
-				URL:      "https://youtube.com/watch?v=" + vid,
-				Source:   "youtube",
-			})
-		}
+	if f.quotaExhausted.CompareAndSwap(false, true) {
+		log.Println("=> YouTube quota exceeded, falling back to yt-dlp search.")
 	}
-
-	return results, nil
+	return true
 }
 
-func fetchDurationsForVideos(results []*SearchResult) (map[string]int, error) {
-	apiKey := os.Getenv("YOUTUBE_API_KEY")
-	ctx := context.Background()
-	service, err := youtube.NewService(ctx, option.WithAPIKey(apiKey))
-	if err != nil {
-		return nil, err
-	}
-
-	var ids []string
-	for _, r := range results {
-		ids = append(ids, r.ID)
-	}
-	idStr := strings.Join(ids, ",")
+// IsQuotaExceeded reports whether err comes from the YouTube Data API running out of quota.
+func IsQuotaExceeded(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "quotaExceeded")
+}
 
-	call := service.Videos.List([]string{"contentDetails"}).Id(idStr)
-	resp, err := call.Do()
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch videos info: %w", err)
+// FormatVideoDuration converts seconds into a duration string like "4:20" or "1:10:25".
+func FormatVideoDuration(seconds int) string {
+	hours := seconds / 3600
+	minutes := (seconds % 3600) / 60
+	secs := seconds % 60
+	if hours > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", hours, minutes, secs)
 	}
+	return fmt.Sprintf("%d:%02d", minutes, secs)
+}
 
-	durations := make(map[string]int)
-	for _, item := range resp.Items {
-		isoDur := item.ContentDetails.Duration
-		seconds := parseISO8601Duration(isoDur)
-		durations[item.Id] = seconds
+// parseVideoDuration converts a duration string like "4:20" or "1:10:25" into seconds.
+func parseVideoDuration(durationStr string) int {
+	parts := strings.Split(durationStr, ":")
+	if len(parts) == 1 {
+		// only seconds
+		return toInt(parts[0])
+	} else if len(parts) == 2 {
+		// mm:ss
+		minutes := toInt(parts[0])
+		seconds := toInt(parts[1])
+		return (minutes * 60) + seconds
+	} else if len(parts) == 3 {
+		// hh:mm:ss
+		hours := toInt(parts[0])
+		minutes := toInt(parts[1])
+		seconds := toInt(parts[2])
+		return (hours * 3600) + (minutes * 60) + seconds
 	}
-	return durations, nil
+	return 0
 }
 
-// parseISO8601Duration convert a duration string like “PT4M20S” into seconds.
-func parseISO8601Duration(isoDur string) int {
-	isoDur = strings.TrimPrefix(isoDur, "PT")
-
-	var hours, mins, secs int
-	if idx := strings.Index(isoDur, "H"); idx != -1 {
-		_, err := fmt.Sscanf(isoDur[:idx], "%d", &hours)
-		if err != nil {
-			return 0
-		}
-		isoDur = isoDur[idx+1:]
-	}
-	if idx := strings.Index(isoDur, "M"); idx != -1 {
-		_, err := fmt.Sscanf(isoDur[:idx], "%d", &mins)
-		if err != nil {
-			return 0
-		}
-		isoDur = isoDur[idx+1:]
-	}
-	if idx := strings.Index(isoDur, "S"); idx != -1 {
-		_, err := fmt.Sscanf(isoDur[:idx], "%d", &secs)
-		if err != nil {
-			return 0
-		}
-		isoDur = isoDur[idx+1:]
+func toInt(s string) int {
+	var val int
+	_, err := fmt.Sscanf(s, "%d", &val)
+	if err != nil {
+		return 0
 	}
-
-	return hours*3600 + mins*60 + secs
+	return val
 }
 
 //
@@ -164,33 +184,3 @@ func parseISO8601Duration(isoDur string) int {
 //		"primaryContents", "sectionListRenderer", "contents", container, "itemSectionRenderer", "contents")
 //	return contents
 //}
-//
-//// parseVideoDuration converts a duration string like "4:20" or "1:10:25" into seconds.
-//func parseVideoDuration(durationStr string) int {
-//	parts := strings.Split(durationStr, ":")
-//	if len(parts) == 1 {
-//		// only seconds
-//		return toInt(parts[0])
-//	} else if len(parts) == 2 {
-//		// mm:ss
-//		minutes := toInt(parts[0])
-//		seconds := toInt(parts[1])
-//		return (minutes * 60) + seconds
-//	} else if len(parts) == 3 {
-//		// hh:mm:ss
-//		hours := toInt(parts[0])
-//		minutes := toInt(parts[1])
-//		seconds := toInt(parts[2])
-//		return (hours * 3600) + (minutes * 60) + seconds
-//	}
-//	return 0
-//}
-//
-//func toInt(s string) int {
-//	var val int
-//	_, err := fmt.Sscanf(s, "%d", &val)
-//	if err != nil {
-//		return 0
-//	}
-//	return val
-//}
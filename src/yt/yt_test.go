@@ -0,0 +1,112 @@
+package youtube
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+// fakeSearcher answers every query with the same results, or with err, and records the
+// queries it gets.
+type fakeSearcher struct {
+	results   []*SearchResult
+	durations map[string]int
+	err       error
+
+	mu      sync.Mutex
+	queries []string
+}
+
+func (f *fakeSearcher) Search(ctx context.Context, query string, limit int64) ([]*SearchResult, error) {
+	f.mu.Lock()
+	f.queries = append(f.queries, query)
+	f.mu.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+	if int64(len(f.results)) > limit {
+		return f.results[:limit], nil
+	}
+	return f.results, nil
+}
+
+func (f *fakeSearcher) Durations(ctx context.Context, results []*SearchResult) (map[string]int, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return f.durations, nil
+}
+
+func (f *fakeSearcher) calls() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.queries)
+}
+
+var errQuota = errors.New("googleapi: Error 403: The request cannot be completed because you have exceeded your quota., quotaExceeded")
+
+func TestFallbackSearcherSwitchesOnQuota(t *testing.T) {
+	primary := &fakeSearcher{err: errQuota}
+	fallback := &fakeSearcher{results: []*SearchResult{{ID: "fallback", Duration: "3:00"}}}
+	f := &FallbackSearcher{Primary: primary, Fallback: fallback}
+
+	for i := 0; i < 3; i++ {
+		results, err := f.Search(context.Background(), "query", 10)
+		if err != nil {
+			t.Fatalf("search %d: unexpected error: %v", i, err)
+		}
+		if len(results) != 1 || results[0].ID != "fallback" {
+			t.Fatalf("search %d: got %v, want the fallback result", i, results)
+		}
+	}
+	if primary.calls() != 1 {
+		t.Errorf("primary searched %d times, want 1: it must not be asked again after quotaExceeded", primary.calls())
+	}
+	if fallback.calls() != 3 {
+		t.Errorf("fallback searched %d times, want 3", fallback.calls())
+	}
+}
+
+func TestFallbackSearcherKeepsOtherErrors(t *testing.T) {
+	primary := &fakeSearcher{err: errors.New("network down")}
+	fallback := &fakeSearcher{results: []*SearchResult{{ID: "fallback"}}}
+	f := &FallbackSearcher{Primary: primary, Fallback: fallback}
+
+	if _, err := f.Search(context.Background(), "query", 10); err == nil || IsQuotaExceeded(err) {
+		t.Fatalf("got error %v, want the primary's own error", err)
+	}
+	if fallback.calls() != 0 {
+		t.Errorf("fallback searched %d times, want 0", fallback.calls())
+	}
+
+	primary.err = nil
+	primary.results = []*SearchResult{{ID: "primary"}}
+	results, err := f.Search(context.Background(), "query", 10)
+	if err != nil || len(results) != 1 || results[0].ID != "primary" {
+		t.Fatalf("got %v, %v, want the primary result", results, err)
+	}
+}
+
+func TestFallbackSearcherDurations(t *testing.T) {
+	primary := &fakeSearcher{durations: map[string]int{"a": 100}}
+	fallback := &fakeSearcher{durations: map[string]int{"a": 200}}
+	f := &FallbackSearcher{Primary: primary, Fallback: fallback}
+
+	durations, err := f.Durations(context.Background(), []*SearchResult{{ID: "a"}})
+	if err != nil || durations["a"] != 100 {
+		t.Fatalf("got %v, %v, want the primary durations", durations, err)
+	}
+
+	// Results of the fallback carry their duration: the primary is not asked
+	durations, err = f.Durations(context.Background(), []*SearchResult{{ID: "a", Duration: "3:20"}})
+	if err != nil || durations["a"] != 200 {
+		t.Fatalf("got %v, %v, want the fallback durations", durations, err)
+	}
+
+	primary.err = errQuota
+	durations, err = f.Durations(context.Background(), []*SearchResult{{ID: "a"}})
+	if err != nil || durations["a"] != 200 {
+		t.Fatalf("got %v, %v, want the fallback durations after quotaExceeded", durations, err)
+	}
+}
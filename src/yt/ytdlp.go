@@ -0,0 +1,70 @@
+package youtube
+
+import (
+	"context"
+	"fmt"
+	"playlist-download/src/utils"
+	"strings"
+
+	"github.com/buger/jsonparser"
+)
+
+// YtDlpSearcher searches YouTube through yt-dlp, which needs no API key and has no quota.
+type YtDlpSearcher struct{}
+
+func (y *YtDlpSearcher) Search(ctx context.Context, query string, limit int64) ([]*SearchResult, error) {
+	searchTerm := fmt.Sprintf("ytsearch%d:%s", limit, query)
+	output, err := utils.RunCmdContext(ctx, "yt-dlp", searchTerm, "--dump-json", "--flat-playlist")
+	if err != nil {
+		return nil, fmt.Errorf("yt-dlp search error: %w", err)
+	}
+
+	var results []*SearchResult
+	// yt-dlp prints one JSON object per line
+	for _, line := range strings.Split(string(output), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		data := []byte(line)
+
+		vid, err := jsonparser.GetString(data, "id")
+		if err != nil || vid == "" {
+			continue
+		}
+		title, _ := jsonparser.GetString(data, "title")
+		uploader, _ := jsonparser.GetString(data, "channel")
+		if uploader == "" {
+			uploader, _ = jsonparser.GetString(data, "uploader")
+		}
+		liveStatus, _ := jsonparser.GetString(data, "live_status")
+
+		duration := ""
+		if secs, err := jsonparser.GetFloat(data, "duration"); err == nil && secs > 0 {
+			duration = FormatVideoDuration(int(secs))
+		}
+
+		results = append(results, &SearchResult{
+			Title:    title,
+			Uploader: uploader,
+			ID:       vid,
+			URL:      "https://youtube.com/watch?v=" + vid,
+			Duration: duration,
+			Live:     liveStatus == "is_live",
+			Source:   "yt-dlp",
+		})
+	}
+
+	return results, nil
+}
+
+// Durations reads the durations yt-dlp already returned with the search results.
+func (y *YtDlpSearcher) Durations(ctx context.Context, results []*SearchResult) (map[string]int, error) {
+	durations := make(map[string]int)
+	for _, r := range results {
+		if r.Duration != "" {
+			durations[r.ID] = parseVideoDuration(r.Duration)
+		}
+	}
+	return durations, nil
+}